@@ -2,13 +2,18 @@ package main
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/csv"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"math"
 	"os"
 	"path/filepath"
 	"runtime"
+	"runtime/pprof"
 	"sort"
 	"strconv"
 	"strings"
@@ -19,6 +24,9 @@ import (
 // 数据行
 type DataRow map[string]string
 
+// 进度和汇总信息的输出位置，结果写到标准输出时改为标准错误
+var progress io.Writer = os.Stdout
+
 // 统计结果
 type Stats struct {
     Min     float64
@@ -29,33 +37,107 @@ type Stats struct {
     Median  float64
 }
 
+// 排序键
+type SortKey struct {
+    Field      string
+    Descending bool
+}
+
+// 单个字段的聚合规则
+type AggSpec struct {
+    Field string
+    Funcs []string // min, max, avg, sum, count, median, mode, mode_count
+}
+
+// 滚动窗口聚合规则，如 value:3:avg 表示按排序后的顺序计算3行的移动平均
+type RollingSpec struct {
+    Field  string
+    Window int
+    Func   string // avg, sum, min, max
+}
+
+// 滚动窗口支持的函数
+var rollingFuncs = []string{"avg", "sum", "min", "max"}
+
+// 数值聚合函数，-aggregate 默认计算全部
+var aggFuncs = []string{"min", "max", "avg", "sum", "count", "median"}
+
+// 按字符串值计算的聚合函数: 众数及其出现次数，需通过 -group-agg 指定
+var valueAggFuncs = []string{"mode", "mode_count"}
+
 // 数据处理配置
 type ProcessConfig struct {
-    InputFile  string
-    OutputFile string
-    Delimiter  string
-    NumWorkers int
-    GroupBy    string
-    AggFields  []string
-    SortBy     string
-    SortDesc   bool
-    FilterExpr string
-    Limit      int
+    InputFile    string
+    OutputFile   string
+    Delimiter    string
+    OutDelimiter string // 输出CSV的分隔符，为空时与 Delimiter 相同
+    NumWorkers   int
+    GroupBy      []string
+    AggFields    []string
+    AggSpecs     []AggSpec // 每个字段的聚合函数，为空时对 AggFields 计算全部统计
+    SortKeys     []SortKey
+    FilterExpr   string
+    Limit        int
+    Format       string        // 输出格式: csv, json, ndjson
+    JSONIndent   int           // JSON输出的缩进空格数，0表示紧凑格式
+    Select       []string      // 输出的列及顺序，为空时输出全部列
+    RejectsFile  string        // 写入被跳过行的文件
+    Distinct     []string      // 去重输出的列组合
+    Strict       bool          // 严格模式，表头重复时报错
+    Precision    int           // 数值输出的小数位数
+    JoinFile     string        // 关联的CSV文件
+    JoinKeys     []string      // 关联键，多列时组成组合键
+    Rolling      []RollingSpec // 排序后计算的滚动窗口统计
+    RollingGroup []string      // 按这些列分别计算滚动窗口
+    SkipRows     int           // 表头之前需要跳过的行数
+    Stream       *ndjsonStream // 非空时处理完的行直接以NDJSON写入，不再收集结果
+}
+
+// 边处理边写入的NDJSON输出
+type ndjsonStream struct {
+    w    io.Writer
+    rows int // 已写入的行数
+}
+
+// 关联文件按组合键建立的索引
+type joinTable struct {
+    keys    []string            // 关联键
+    columns []string            // 关联文件中的非键列
+    names   []string            // 非键列在结果中的列名，与左表重名时加序号后缀
+    rows    map[string][]string // 组合键 -> 非键列的值
 }
 
 func main() {
     // 命令行参数
-    inputFile := flag.String("input", "D:\\download\\dest\\summary\\彩讯股份个人电脑安全暨防钓鱼及敏感数据要求及宣贯（20240728）(1).xlsx", "输入CSV文件")
-    outputFile := flag.String("output", "", "输出CSV文件")
+    inputFile := flag.String("input", "D:\\download\\dest\\summary\\彩讯股份个人电脑安全暨防钓鱼及敏感数据要求及宣贯（20240728）(1).xlsx", "输入CSV文件(- 表示标准输入)")
+    outputFile := flag.String("output", "", "输出CSV文件(- 表示标准输出)")
     delimiter := flag.String("delimiter", ",", "字段分隔符")
+    skipRows := flag.Int("skip-rows", 0, "跳过表头之前的 N 行(如导出文件开头的标题或说明)")
+    outDelimiter := flag.String("out-delimiter", "", "输出CSV的字段分隔符(为空时与 -delimiter 相同)")
     workers := flag.Int("workers", runtime.NumCPU(), "并发工作器数量")
-    groupBy := flag.String("group", "", "分组字段")
+    groupBy := flag.String("group", "", "分组字段(逗号分隔，支持多列)")
     aggregate := flag.String("aggregate", "", "聚合计算的字段(逗号分隔)")
-    sortBy := flag.String("sort", "", "排序字段")
-    sortDesc := flag.Bool("desc", false, "降序排序")
+    groupAgg := flag.String("group-agg", "", "按字段指定聚合函数(如 revenue:sum,sessions:avg,id:count,city:mode)")
+    sortBy := flag.String("sort", "", "排序字段(逗号分隔，可加 :asc/:desc，如 price:desc,name:asc)")
+    sortDesc := flag.Bool("desc", false, "未指定方向的排序字段使用降序")
     filterExpr := flag.String("filter", "", "过滤表达式")
     limit := flag.Int("limit", 0, "结果限制")
     showMemory := flag.Bool("memory", false, "显示内存使用情况")
+    format := flag.String("format", "csv", "输出格式(csv, json, ndjson)")
+    rejectsFile := flag.String("rejects", "", "将字段数不匹配的行写入该文件")
+    precision := flag.Int("precision", 2, "数值输出的小数位数(计数始终为整数)")
+    strict := flag.Bool("strict", false, "严格模式: 表头重复时报错而不是自动重命名")
+    distinct := flag.String("distinct", "", "输出这些列的不重复组合(逗号分隔)")
+    selectCols := flag.String("select", "", "输出的列(逗号分隔，按给定顺序)")
+    jsonIndent := flag.Int("json-indent", 0, "JSON输出缩进空格数(0为紧凑格式，仅用于 -format json)")
+    joinFile := flag.String("join", "", "按关联键关联的CSV文件(内连接)")
+    joinKey := flag.String("join-key", "", "关联键(逗号分隔，多列时组成组合键)")
+    rolling := flag.String("rolling", "", "排序后计算滚动窗口统计，字段:窗口行数:函数(avg, sum, min, max)，如 value:3:avg")
+    rollingGroup := flag.String("rolling-group", "", "按这些列分别计算滚动窗口(逗号分隔)")
+    inferTypes := flag.Bool("infer-types", false, "根据抽样行推断每列的类型(int, float, bool, date, string)并输出")
+    typesFile := flag.String("types-file", "", "将推断的列类型写入该CSV文件(需要 -infer-types)")
+    cpuProfile := flag.String("cpuprofile", "", "将 processCSV 期间的CPU profile写入该文件")
+    memProfile := flag.String("memprofile", "", "处理结束后将堆内存 profile 写入该文件")
     flag.Parse()
 
     if *inputFile == "" {
@@ -64,43 +146,150 @@ func main() {
         return
     }
 
+    switch *format {
+    case "csv", "json", "ndjson":
+    default:
+        fmt.Printf("不支持的输出格式: %s\n", *format)
+        flag.Usage()
+        return
+    }
+
     // 配置处理
     config := ProcessConfig{
-        InputFile:  *inputFile,
-        OutputFile: *outputFile,
-        Delimiter:  *delimiter,
-        NumWorkers: *workers,
-        GroupBy:    *groupBy,
-        SortBy:     *sortBy,
-        SortDesc:   *sortDesc,
-        FilterExpr: *filterExpr,
-        Limit:      *limit,
+        InputFile:    *inputFile,
+        OutputFile:   *outputFile,
+        Delimiter:    *delimiter,
+        OutDelimiter: *outDelimiter,
+        NumWorkers:   *workers,
+        SortKeys:     parseSortSpec(*sortBy, *sortDesc),
+        FilterExpr:   *filterExpr,
+        Limit:        *limit,
+        Format:       *format,
+        JSONIndent:   *jsonIndent,
+        RejectsFile:  *rejectsFile,
+        Strict:       *strict,
+        Precision:    *precision,
+        SkipRows:     *skipRows,
+    }
+
+    if config.Precision < 0 {
+        fmt.Println("-precision 不能为负数")
+        return
+    }
+
+    if config.SkipRows < 0 {
+        fmt.Println("-skip-rows 不能为负数")
+        return
+    }
+
+    if *groupBy != "" {
+        config.GroupBy = strings.Split(*groupBy, ",")
     }
 
     if *aggregate != "" {
         config.AggFields = strings.Split(*aggregate, ",")
     }
 
+    if *groupAgg != "" {
+        specs, err := parseAggSpecs(*groupAgg)
+        if err != nil {
+            fmt.Printf("无效的聚合规则: %v\n", err)
+            return
+        }
+        config.AggSpecs = specs
+        config.AggFields = nil
+        for _, spec := range specs {
+            config.AggFields = append(config.AggFields, spec.Field)
+        }
+    }
+
+    if *selectCols != "" {
+        config.Select = strings.Split(*selectCols, ",")
+    }
+
+    if *distinct != "" {
+        if len(config.GroupBy) > 0 {
+            fmt.Println("-distinct 不能与 -group 同时使用")
+            return
+        }
+        config.Distinct = strings.Split(*distinct, ",")
+    }
+
+    if *joinFile != "" {
+        if *joinKey == "" {
+            fmt.Println("-join 需要同时指定 -join-key")
+            return
+        }
+        config.JoinFile = *joinFile
+        config.JoinKeys = strings.Split(*joinKey, ",")
+    }
+
+    if *rolling != "" {
+        specs, err := parseRollingSpecs(*rolling)
+        if err != nil {
+            fmt.Printf("无效的滚动窗口规则: %v\n", err)
+            return
+        }
+        config.Rolling = specs
+        if *rollingGroup != "" {
+            config.RollingGroup = strings.Split(*rollingGroup, ",")
+        }
+    }
+
+    if *outputFile == "-" {
+        progress = os.Stderr
+    }
+
     // 开始计时
     startTime := time.Now()
 
     // 打印初始信息
-    fmt.Printf("开始处理文件: %s\n", *inputFile)
-    fmt.Printf("并发工作器: %d\n", *workers)
+    fmt.Fprintf(progress, "开始处理文件: %s\n", *inputFile)
+    fmt.Fprintf(progress, "并发工作器: %d\n", *workers)
 
+    // 开始CPU分析
+    stopCPUProfile := func() {}
+    if *cpuProfile != "" {
+        stop, err := startCPUProfile(*cpuProfile)
+        if err != nil {
+            fmt.Fprintf(progress, "启动CPU分析失败: %v\n", err)
+            return
+        }
+        stopCPUProfile = stop
+    }
+    
+    // 结果不需要整体排序或汇总时，NDJSON 直接逐行写入输出
+    closeOutput := func() {}
+    if *outputFile != "" && config.streamable() && !*inferTypes {
+        out, closeFn, err := openOutput(*outputFile)
+        if err != nil {
+            stopCPUProfile()
+            fmt.Fprintf(progress, "写入结果失败: %v\n", err)
+            return
+        }
+        config.Stream = &ndjsonStream{w: out}
+        closeOutput = closeFn
+    }
+    
     // 处理数据
     results, headers, err := processCSV(config)
+    stopCPUProfile()
+    closeOutput()
     if err != nil {
-        fmt.Printf("处理失败: %v\n", err)
+        fmt.Fprintf(progress, "处理失败: %v\n", err)
         return
     }
+    rowCount := len(results)
 
     // 输出结果
-    if *outputFile != "" {
-        if err := writeResults(*outputFile, results, headers); err != nil {
-            fmt.Printf("写入结果失败: %v\n", err)
+    if config.Stream != nil {
+        rowCount = config.Stream.rows
+        fmt.Fprintf(progress, "结果已写入: %s\n", *outputFile)
+    } else if *outputFile != "" {
+        if err := writeResults(config, results, headers); err != nil {
+            fmt.Fprintf(progress, "写入结果失败: %v\n", err)
         } else {
-            fmt.Printf("结果已写入: %s\n", *outputFile)
+            fmt.Fprintf(progress, "结果已写入: %s\n", *outputFile)
         }
     } else {
         // 显示前几行
@@ -129,31 +318,211 @@ func main() {
         }
     }
 
+    // 推断列类型
+    if *inferTypes {
+        types := inferColumnTypes(results, headers, typeSampleRows)
+        fmt.Fprintln(progress, "\n列类型:")
+        for _, header := range headers {
+            fmt.Fprintf(progress, "%s: %s\n", header, types[header])
+        }
+        if *typesFile != "" {
+            if err := writeColumnTypes(*typesFile, headers, types); err != nil {
+                fmt.Fprintf(progress, "写入列类型失败: %v\n", err)
+            } else {
+                fmt.Fprintf(progress, "列类型已写入: %s\n", *typesFile)
+            }
+        }
+    }
+
     // 报告执行时间
     elapsed := time.Since(startTime)
-    fmt.Printf("\n处理完成，耗时: %v\n", elapsed)
-    fmt.Printf("处理速度: %.2f 行/秒\n", float64(len(results))/elapsed.Seconds())
+    fmt.Fprintf(progress, "\n处理完成，耗时: %v\n", elapsed)
+    fmt.Fprintf(progress, "处理速度: %.2f 行/秒\n", float64(rowCount)/elapsed.Seconds())
 
+    // 写入堆内存分析
+    if *memProfile != "" {
+        if err := writeHeapProfile(*memProfile); err != nil {
+            fmt.Fprintf(progress, "写入内存分析失败: %v\n", err)
+        } else {
+            fmt.Fprintf(progress, "内存分析已写入: %s\n", *memProfile)
+        }
+    }
+    
     // 显示内存使用
     if *showMemory {
         var m runtime.MemStats
         runtime.ReadMemStats(&m)
-        fmt.Printf("内存使用: %.2f MB\n", float64(m.Alloc)/1024/1024)
+        fmt.Fprintf(progress, "内存使用: %.2f MB\n", float64(m.Alloc)/1024/1024)
     }
 }
 
-// 处理CSV文件
-func processCSV(config ProcessConfig) ([]DataRow, []string, error) {
-    // 打开输入文件
-    file, err := os.Open(config.InputFile)
+// 推断列类型时抽样的行数
+const typeSampleRows = 1000
+
+// 按从具体到宽泛的顺序排列的列类型，string 可以容纳任何值
+var columnTypes = []string{"bool", "int", "float", "date", "string"}
+
+// 识别为日期的格式
+var dateLayouts = []string{
+    "2006-01-02",
+    "2006/01/02",
+    "2006-01-02 15:04:05",
+    time.RFC3339,
+}
+
+// 判断单个值能否解析为指定类型
+func parsesAs(val string, typ string) bool {
+    switch typ {
+    case "bool":
+        lower := strings.ToLower(val)
+        return lower == "true" || lower == "false"
+    case "int":
+        _, err := strconv.ParseInt(val, 10, 64)
+        return err == nil
+    case "float":
+        _, err := strconv.ParseFloat(val, 64)
+        return err == nil
+    case "date":
+        for _, layout := range dateLayouts {
+            if _, err := time.Parse(layout, val); err == nil {
+                return true
+            }
+        }
+        return false
+    }
+    return true
+}
+
+// 根据前 sample 行推断每列的类型: 取超过半数非空值能解析成功的最具体类型，
+// 全部为空的列视为 string
+func inferColumnTypes(rows []DataRow, headers []string, sample int) map[string]string {
+    if len(rows) > sample {
+        rows = rows[:sample]
+    }
+    
+    types := make(map[string]string, len(headers))
+    for _, header := range headers {
+        counts := make(map[string]int, len(columnTypes))
+        nonEmpty := 0
+        for _, row := range rows {
+            val := strings.TrimSpace(row[header])
+            if val == "" {
+                continue
+            }
+            nonEmpty++
+            for _, typ := range columnTypes {
+                if parsesAs(val, typ) {
+                    counts[typ]++
+                }
+            }
+        }
+        
+        types[header] = "string"
+        for _, typ := range columnTypes {
+            if nonEmpty > 0 && counts[typ]*2 > nonEmpty {
+                types[header] = typ
+                break
+            }
+        }
+    }
+    
+    return types
+}
+
+// 将列类型写入 column,type 格式的CSV文件
+func writeColumnTypes(path string, headers []string, types map[string]string) error {
+    file, err := os.Create(path)
+    if err != nil {
+        return err
+    }
+    defer file.Close()
+    
+    writer := csv.NewWriter(file)
+    writer.Write([]string{"column", "type"})
+    for _, header := range headers {
+        writer.Write([]string{header, types[header]})
+    }
+    writer.Flush()
+    
+    return writer.Error()
+}
+
+// 开始CPU分析，返回停止分析并关闭文件的函数
+func startCPUProfile(path string) (func(), error) {
+    file, err := os.Create(path)
     if err != nil {
-        return nil, nil, fmt.Errorf("无法打开文件: %v", err)
+        return nil, err
+    }
+    if err := pprof.StartCPUProfile(file); err != nil {
+        file.Close()
+        return nil, err
+    }
+    return func() {
+        pprof.StopCPUProfile()
+        file.Close()
+    }, nil
+}
+
+// 写入当前的堆内存分析
+func writeHeapProfile(path string) error {
+    file, err := os.Create(path)
+    if err != nil {
+        return err
     }
     defer file.Close()
+    
+    // 先触发GC，使分析结果反映最新的存活对象
+    runtime.GC()
+    return pprof.WriteHeapProfile(file)
+}
 
+// 处理CSV文件
+func processCSV(config ProcessConfig) ([]DataRow, []string, error) {
+    var input io.Reader
+    estimatedRows := 0
+    
+    if config.InputFile == "-" {
+        // 标准输入无法 Stat/Seek，不估计行数
+        input = os.Stdin
+    } else {
+        // 打开输入文件
+        file, err := os.Open(config.InputFile)
+        if err != nil {
+            return nil, nil, fmt.Errorf("无法打开文件: %v", err)
+        }
+        defer file.Close()
+        
+        // 估计文件大小和行数
+        fileInfo, err := file.Stat()
+        if err != nil {
+            return nil, nil, fmt.Errorf("获取文件信息失败: %v", err)
+        }
+        
+        fileSize := fileInfo.Size()
+        estimatedRows = estimateRowCount(file, fileSize) - config.SkipRows
+        if estimatedRows < 0 {
+            estimatedRows = 0
+        }
+        fmt.Fprintf(progress, "估计数据行数: 约 %d 行\n", estimatedRows)
+        
+        // 重置文件指针
+        file.Seek(0, 0)
+        input = file
+    }
+    
+    // 跳过表头之前的行，按原始行处理，不要求符合CSV格式
+    if config.SkipRows > 0 {
+        buffered := bufio.NewReader(input)
+        if err := skipLines(buffered, config.SkipRows); err != nil {
+            return nil, nil, err
+        }
+        input = buffered
+    }
+    
     // 创建CSV读取器
-    reader := csv.NewReader(file)
+    reader := csv.NewReader(input)
     reader.Comma = []rune(config.Delimiter)[0]
+    reader.FieldsPerRecord = -1 // 字段数由下面自行校验
     
     // 读取表头
     headers, err := reader.Read()
@@ -161,24 +530,58 @@ func processCSV(config ProcessConfig) ([]DataRow, []string, error) {
         return nil, nil, fmt.Errorf("读取表头失败: %v", err)
     }
     
-    // 估计文件大小和行数
-    fileInfo, err := file.Stat()
-    if err != nil {
-        return nil, nil, fmt.Errorf("获取文件信息失败: %v", err)
+    // 检查重复的表头，避免数据行中同名列互相覆盖
+    if uniqueHeaders, duplicates := disambiguateHeaders(headers); len(duplicates) > 0 {
+        if config.Strict {
+            return nil, nil, fmt.Errorf("表头存在重复的列名: %s", strings.Join(duplicates, ", "))
+        }
+        fmt.Fprintf(progress, "警告: 表头存在重复的列名 %s，已重命名为: %s\n",
+            strings.Join(duplicates, ", "), strings.Join(uniqueHeaders, ", "))
+        headers = uniqueHeaders
     }
     
-    fileSize := fileInfo.Size()
-    estimatedRows := estimateRowCount(file, fileSize)
-    fmt.Printf("估计数据行数: 约 %d 行\n", estimatedRows)
+    // 读取关联文件，非键列追加到表头之后
+    inputHeaders := headers
+    var join *joinTable
+    if config.JoinFile != "" {
+        if join, err = loadJoinTable(config.JoinFile, reader.Comma, config.JoinKeys, headers); err != nil {
+            return nil, nil, err
+        }
+        headers = append(append([]string(nil), inputHeaders...), join.names...)
+    }
     
-    // 重置文件指针
-    file.Seek(0, 0)
-    reader = csv.NewReader(file)
-    reader.Comma = []rune(config.Delimiter)[0]
-    _, _ = reader.Read() // 跳过表头
+    // 校验去重列
+    var distinctCols []string
+    if len(config.Distinct) > 0 {
+        if distinctCols, err = selectColumns(headers, config.Distinct); err != nil {
+            return nil, nil, err
+        }
+    }
+    
+    // 被跳过的行写入拒绝文件
+    var rejects *csv.Writer
+    if config.RejectsFile != "" {
+        rejectsFile, err := os.Create(config.RejectsFile)
+        if err != nil {
+            return nil, nil, fmt.Errorf("创建拒绝行文件失败: %v", err)
+        }
+        defer rejectsFile.Close()
+        
+        rejects = csv.NewWriter(rejectsFile)
+        rejects.Comma = reader.Comma
+        rejects.Write(inputHeaders)
+    }
+    
+    // 逐行输出时结果不经过分组或排序，提前确定输出列
+    if config.Stream != nil && len(config.Select) > 0 {
+        if headers, err = selectColumns(headers, config.Select); err != nil {
+            return nil, nil, err
+        }
+    }
     
     // 创建工作池
     rows := make(chan DataRow, 10000)
+    processed := make(chan DataRow, 10000)
     results := make([]DataRow, 0, estimatedRows)
     var wg sync.WaitGroup
     
@@ -194,31 +597,72 @@ func processCSV(config ProcessConfig) ([]DataRow, []string, error) {
                     continue
                 }
                 
-                // 处理数据行
-                processRow(row, config.AggFields)
+                // 关联另一个文件，未匹配的行丢弃
+                if join != nil && !join.merge(row) {
+                    continue
+                }
+                
+                // 处理数据行，分组时保留原始值以免聚合前丢失精度
+                if len(config.GroupBy) == 0 {
+                    processRow(row, config.AggFields, config.Precision)
+                }
+                processed <- row
             }
         }()
     }
     
-    // 读取和分配行
+    // 所有工作协程结束后关闭结果通道
+    go func() {
+        wg.Wait()
+        close(processed)
+    }()
+    
+    // 读取和分配行，非格式错误的读取失败会中止读取，在所有行处理完后返回
+    var readErr error
     go func() {
-        scanner := bufio.NewScanner(file)
-        // 跳过已读的表头
-        scanner.Scan()
-        
         lineCount := 0
-        for scanner.Scan() {
+        skippedRows := 0
+        var skippedLines []int // 被跳过行的示例行号
+        
+        skip := func(line int, fields []string) {
+            skippedRows++
+            if len(skippedLines) < maxSkippedSamples {
+                skippedLines = append(skippedLines, line)
+            }
+            if rejects != nil && fields != nil {
+                rejects.Write(fields)
+            }
+        }
+        
+        for {
+            // 使用CSV读取器解析，保留引号内的分隔符
+            fields, err := reader.Read()
+            if err == io.EOF {
+                break
+            }
+            if err != nil {
+                // 跳过格式错误的行，其他错误(如I/O错误)重试也不会恢复
+                var parseErr *csv.ParseError
+                if !errors.As(err, &parseErr) {
+                    readErr = fmt.Errorf("读取第 %d 行数据失败: %v", lineCount+1, err)
+                    break
+                }
+                lineCount++
+                skip(parseErr.StartLine+config.SkipRows, nil)
+                continue
+            }
             lineCount++
-            line := scanner.Text()
-            fields := strings.Split(line, config.Delimiter)
             
-            if len(fields) != len(headers) {
-                continue // 跳过字段数不匹配的行
+            if len(fields) != len(inputHeaders) {
+                // 跳过字段数不匹配的行
+                line, _ := reader.FieldPos(0)
+                skip(line+config.SkipRows, fields)
+                continue
             }
             
             // 创建数据行
             row := make(DataRow)
-            for i, header := range headers {
+            for i, header := range inputHeaders {
                 row[header] = fields[i]
             }
             
@@ -226,30 +670,66 @@ func processCSV(config ProcessConfig) ([]DataRow, []string, error) {
             
             // 每处理10万行打印一次进度
             if lineCount%100000 == 0 {
-                fmt.Printf("已处理 %d 行...\n", lineCount)
+                fmt.Fprintf(progress, "已处理 %d 行...\n", lineCount)
             }
         }
         
+        fmt.Fprintf(progress, "共读取 %d 行数据\n", lineCount)
+        if skippedRows > 0 {
+            samples := make([]string, len(skippedLines))
+            for i, line := range skippedLines {
+                samples[i] = strconv.Itoa(line)
+            }
+            fmt.Fprintf(progress, "跳过 %d 行格式错误或字段数不匹配的数据 (示例行号: %s)\n",
+                skippedRows, strings.Join(samples, ", "))
+        }
+        if rejects != nil {
+            rejects.Flush()
+        }
+        
         close(rows)
-        fmt.Printf("共读取 %d 行数据\n", lineCount)
     }()
     
-    // 等待处理完成
-    wg.Wait()
+    // 逐行写入，需读完所有行以等待读取协程写完拒绝文件
+    if config.Stream != nil {
+        if err := config.Stream.write(processed, headers, numericColumns(config), config.Limit); err != nil {
+            return nil, nil, err
+        }
+        return nil, headers, readErr
+    }
     
     // 处理分组和聚合
-    if config.GroupBy != "" {
-        results = groupAndAggregate(rows, config.GroupBy, config.AggFields)
+    if len(config.GroupBy) > 0 {
+        results = groupAndAggregate(processed, config.GroupBy, config.aggSpecs(), config.Precision)
+        headers = aggregateHeaders(config.GroupBy, config.aggSpecs())
     } else {
         // 将所有行收集到结果集
-        for row := range rows {
+        for row := range processed {
             results = append(results, row)
         }
     }
+    if readErr != nil {
+        return nil, nil, readErr
+    }
+    
+    // 投影到去重列并去除重复组合，-distinct 不能与 -group 同时使用
+    if len(distinctCols) > 0 {
+        results = distinctRows(results, distinctCols)
+        headers = distinctCols
+    }
     
     // 排序结果
-    if config.SortBy != "" {
-        sortResults(results, config.SortBy, config.SortDesc)
+    if len(config.SortKeys) > 0 {
+        sortResults(results, config.SortKeys)
+    }
+    
+    // 按排序后的顺序计算滚动窗口
+    if len(config.Rolling) > 0 {
+        applyRolling(results, config.Rolling, config.RollingGroup, config.Precision)
+        headers = append([]string(nil), headers...)
+        for _, spec := range config.Rolling {
+            headers = append(headers, spec.column())
+        }
     }
     
     // 限制结果数量
@@ -257,9 +737,190 @@ func processCSV(config ProcessConfig) ([]DataRow, []string, error) {
         results = results[:config.Limit]
     }
     
+    // 投影到选定的列
+    if len(config.Select) > 0 {
+        headers, err = selectColumns(headers, config.Select)
+        if err != nil {
+            return nil, nil, err
+        }
+    }
+    
     return results, headers, nil
 }
 
+// 读取关联文件并按关联键建立索引，关联键必须同时存在于两个文件中
+func loadJoinTable(path string, comma rune, keys []string, leftHeaders []string) (*joinTable, error) {
+    file, err := os.Open(path)
+    if err != nil {
+        return nil, fmt.Errorf("无法打开关联文件: %v", err)
+    }
+    defer file.Close()
+    
+    reader := csv.NewReader(file)
+    reader.Comma = comma
+    headers, err := reader.Read()
+    if err != nil {
+        return nil, fmt.Errorf("读取关联文件表头失败: %v", err)
+    }
+    headers, _ = disambiguateHeaders(headers)
+    
+    if keys, err = selectColumns(leftHeaders, keys); err != nil {
+        return nil, err
+    }
+    if _, err = selectColumns(headers, keys); err != nil {
+        return nil, fmt.Errorf("关联文件中%v", err)
+    }
+    
+    isKey := make(map[string]bool, len(keys))
+    for _, key := range keys {
+        isKey[key] = true
+    }
+    
+    join := &joinTable{keys: keys, rows: make(map[string][]string)}
+    var columnIndex []int
+    for i, header := range headers {
+        if !isKey[header] {
+            join.columns = append(join.columns, header)
+            columnIndex = append(columnIndex, i)
+        }
+    }
+    
+    // 与左表重名的列加序号后缀
+    combined, _ := disambiguateHeaders(append(append([]string(nil), leftHeaders...), join.columns...))
+    join.names = combined[len(leftHeaders):]
+    
+    duplicates := 0
+    for {
+        fields, err := reader.Read()
+        if err == io.EOF {
+            break
+        }
+        if err != nil {
+            return nil, fmt.Errorf("读取关联文件失败: %v", err)
+        }
+        
+        row := make(DataRow, len(headers))
+        for i, header := range headers {
+            row[header] = fields[i]
+        }
+        
+        // 与分组使用相同的组合键，重复的键保留第一行
+        key := groupKey(row, keys)
+        if _, exists := join.rows[key]; exists {
+            duplicates++
+            continue
+        }
+        values := make([]string, len(columnIndex))
+        for i, index := range columnIndex {
+            values[i] = fields[index]
+        }
+        join.rows[key] = values
+    }
+    
+    if duplicates > 0 {
+        fmt.Fprintf(progress, "警告: 关联文件中有 %d 行关联键重复，已保留第一行\n", duplicates)
+    }
+    
+    return join, nil
+}
+
+// 将关联文件中匹配的列合并到数据行，没有匹配时返回 false
+func (j *joinTable) merge(row DataRow) bool {
+    values, ok := j.rows[groupKey(row, j.keys)]
+    if !ok {
+        return false
+    }
+    for i, name := range j.names {
+        row[name] = values[i]
+    }
+    return true
+}
+
+// 投影到指定列并去除重复的组合，保留首次出现的顺序
+func distinctRows(rows []DataRow, columns []string) []DataRow {
+    seen := make(map[string]bool)
+    results := make([]DataRow, 0)
+    
+    for _, row := range rows {
+        key := groupKey(row, columns)
+        if seen[key] {
+            continue
+        }
+        seen[key] = true
+        
+        projected := make(DataRow, len(columns))
+        for _, col := range columns {
+            projected[col] = row[col]
+        }
+        results = append(results, projected)
+    }
+    
+    return results
+}
+
+// 为重复的表头添加序号后缀(id, id_2, ...)，返回新的表头和重复的列名
+func disambiguateHeaders(headers []string) ([]string, []string) {
+    used := make(map[string]bool, len(headers))
+    for _, header := range headers {
+        used[header] = true
+    }
+    
+    seen := make(map[string]int, len(headers))
+    unique := make([]string, len(headers))
+    var duplicates []string
+    
+    for i, header := range headers {
+        seen[header]++
+        if seen[header] == 1 {
+            unique[i] = header
+            continue
+        }
+        if seen[header] == 2 {
+            duplicates = append(duplicates, header)
+        }
+        
+        // 跳过已存在的名称，如原表头中已有 id_2
+        n := seen[header]
+        name := fmt.Sprintf("%s_%d", header, n)
+        for used[name] {
+            n++
+            name = fmt.Sprintf("%s_%d", header, n)
+        }
+        used[name] = true
+        unique[i] = name
+    }
+    
+    return unique, duplicates
+}
+
+// 按给定顺序选择输出列，并校验每一列都存在且不重复
+func selectColumns(headers []string, selected []string) ([]string, error) {
+    available := make(map[string]bool, len(headers))
+    for _, header := range headers {
+        available[header] = true
+    }
+    
+    columns := make([]string, 0, len(selected))
+    seen := make(map[string]bool, len(selected))
+    for _, col := range selected {
+        col = strings.TrimSpace(col)
+        if !available[col] {
+            return nil, fmt.Errorf("选择的列不存在: %s (可用列: %s)", col, strings.Join(headers, ", "))
+        }
+        // 重复的列在JSON输出中会产生重复的键
+        if seen[col] {
+            return nil, fmt.Errorf("选择的列重复: %s", col)
+        }
+        seen[col] = true
+        columns = append(columns, col)
+    }
+    
+    return columns, nil
+}
+
+// 汇总中展示的被跳过行号示例数量
+const maxSkippedSamples = 10
+
 // 估计文件的行数
 func estimateRowCount(file *os.File, fileSize int64) int {
     // 读取前10000个字节来估计每行的平均大小
@@ -285,15 +946,28 @@ func estimateRowCount(file *os.File, fileSize int64) int {
     return int(float64(fileSize) / avgLineSize)
 }
 
+// 读取并丢弃前 n 行
+func skipLines(r *bufio.Reader, n int) error {
+    for i := 0; i < n; i++ {
+        if _, err := r.ReadString('\n'); err != nil {
+            if err == io.EOF {
+                return fmt.Errorf("文件只有 %d 行，不足 -skip-rows 指定的 %d 行", i, n)
+            }
+            return fmt.Errorf("跳过开头的行失败: %v", err)
+        }
+    }
+    return nil
+}
+
 // 处理数据行
-func processRow(row DataRow, aggFields []string) {
+func processRow(row DataRow, aggFields []string, precision int) {
     // 对数值字段进行转换
     for _, field := range aggFields {
         if val, ok := row[field]; ok {
             // 尝试将字符串转换为数值，以便后续聚合计算
             if num, err := strconv.ParseFloat(val, 64); err == nil {
                 // 将处理后的值存回行中
-                row[field] = fmt.Sprintf("%.2f", num)
+                row[field] = formatNumber(num, precision)
             }
         }
     }
@@ -318,34 +992,249 @@ func applyFilter(row DataRow, filterExpr string) bool {
     return false
 }
 
+// 组合分组键的分隔符，不会出现在正常的字段值中
+const groupKeySep = "\x1f"
+
+// 根据多个分组字段构造组合键
+func groupKey(row DataRow, groupBy []string) string {
+    if len(groupBy) == 1 {
+        return row[groupBy[0]]
+    }
+    values := make([]string, len(groupBy))
+    for i, field := range groupBy {
+        values[i] = row[field]
+    }
+    return strings.Join(values, groupKeySep)
+}
+
+// 解析聚合规则，如 "revenue:sum,sessions:avg,id:count"，同一字段可出现多次
+func parseAggSpecs(spec string) ([]AggSpec, error) {
+    var specs []AggSpec
+    index := make(map[string]int) // 字段在 specs 中的位置
+    
+    for _, part := range strings.Split(spec, ",") {
+        part = strings.TrimSpace(part)
+        if part == "" {
+            continue
+        }
+        
+        field, fn, ok := strings.Cut(part, ":")
+        field = strings.TrimSpace(field)
+        fn = strings.ToLower(strings.TrimSpace(fn))
+        if !ok || field == "" {
+            return nil, fmt.Errorf("规则格式应为 字段:函数: %s", part)
+        }
+        if !isAggFunc(fn) {
+            return nil, fmt.Errorf("不支持的聚合函数 %s (可用: %s, %s)", fn,
+                strings.Join(aggFuncs, ", "), strings.Join(valueAggFuncs, ", "))
+        }
+        
+        if i, exists := index[field]; exists {
+            specs[i].Funcs = append(specs[i].Funcs, fn)
+            continue
+        }
+        index[field] = len(specs)
+        specs = append(specs, AggSpec{Field: field, Funcs: []string{fn}})
+    }
+    
+    return specs, nil
+}
+
+// 解析滚动窗口规则，如 "value:3:avg,value:7:max"
+func parseRollingSpecs(spec string) ([]RollingSpec, error) {
+    var specs []RollingSpec
+    for _, part := range strings.Split(spec, ",") {
+        part = strings.TrimSpace(part)
+        if part == "" {
+            continue
+        }
+        
+        fields := strings.Split(part, ":")
+        if len(fields) != 3 || strings.TrimSpace(fields[0]) == "" {
+            return nil, fmt.Errorf("规则格式应为 字段:窗口行数:函数: %s", part)
+        }
+        window, err := strconv.Atoi(strings.TrimSpace(fields[1]))
+        if err != nil || window < 1 {
+            return nil, fmt.Errorf("窗口行数必须是正整数: %s", part)
+        }
+        fn := strings.ToLower(strings.TrimSpace(fields[2]))
+        supported := false
+        for _, f := range rollingFuncs {
+            if f == fn {
+                supported = true
+            }
+        }
+        if !supported {
+            return nil, fmt.Errorf("不支持的滚动窗口函数 %s (可用: %s)", fn, strings.Join(rollingFuncs, ", "))
+        }
+        
+        specs = append(specs, RollingSpec{Field: strings.TrimSpace(fields[0]), Window: window, Func: fn})
+    }
+    return specs, nil
+}
+
+// 滚动窗口统计的输出列名，如 value_rolling_avg_3
+func (r RollingSpec) column() string {
+    return fmt.Sprintf("%s_rolling_%s_%d", r.Field, r.Func, r.Window)
+}
+
+// 按行顺序计算滚动窗口统计并写入新列
+// 窗口开头不足 Window 行时按已有的行计算，非数值的行不计入窗口且输出为空，
+// 指定 groupBy 时每组单独维护窗口
+func applyRolling(rows []DataRow, specs []RollingSpec, groupBy []string, precision int) {
+    for _, spec := range specs {
+        column := spec.column()
+        windows := make(map[string][]float64)
+        
+        for _, row := range rows {
+            num, err := strconv.ParseFloat(row[spec.Field], 64)
+            if err != nil {
+                row[column] = ""
+                continue
+            }
+            
+            key := groupKey(row, groupBy)
+            window := append(windows[key], num)
+            if len(window) > spec.Window {
+                window = window[1:]
+            }
+            windows[key] = window
+            
+            row[column] = formatNumber(rollingValue(window, spec.Func), precision)
+        }
+    }
+}
+
+// 计算窗口内数值的统计
+func rollingValue(window []float64, fn string) float64 {
+    result := window[0]
+    sum := 0.0
+    for _, num := range window {
+        sum += num
+        switch fn {
+        case "min":
+            result = math.Min(result, num)
+        case "max":
+            result = math.Max(result, num)
+        }
+    }
+    
+    switch fn {
+    case "sum":
+        return sum
+    case "avg":
+        return sum / float64(len(window))
+    }
+    return result
+}
+
+// 判断是否是支持的聚合函数
+func isAggFunc(fn string) bool {
+    for _, f := range aggFuncs {
+        if f == fn {
+            return true
+        }
+    }
+    return isValueAggFunc(fn)
+}
+
+// 判断是否是按字符串值计算的聚合函数
+func isValueAggFunc(fn string) bool {
+    for _, f := range valueAggFuncs {
+        if f == fn {
+            return true
+        }
+    }
+    return false
+}
+
+// 聚合规则：未指定 -group-agg 时对每个聚合字段计算全部统计
+func (c ProcessConfig) aggSpecs() []AggSpec {
+    if len(c.AggSpecs) > 0 {
+        return c.AggSpecs
+    }
+    specs := make([]AggSpec, 0, len(c.AggFields))
+    for _, field := range c.AggFields {
+        specs = append(specs, AggSpec{Field: field, Funcs: aggFuncs})
+    }
+    return specs
+}
+
+// 分组聚合结果的表头：分组字段在前，聚合统计列在后
+func aggregateHeaders(groupBy []string, specs []AggSpec) []string {
+    headers := append([]string{}, groupBy...)
+    for _, spec := range specs {
+        for _, fn := range spec.Funcs {
+            headers = append(headers, spec.Field+"_"+fn)
+        }
+    }
+    return headers
+}
+
+// 按指定小数位数格式化数值
+func formatNumber(num float64, precision int) string {
+    return strconv.FormatFloat(num, 'f', precision, 64)
+}
+
+// 格式化统计结果中指定聚合函数的值，计数始终为整数
+func formatStat(stats Stats, fn string, precision int) string {
+    switch fn {
+    case "min":
+        return formatNumber(stats.Min, precision)
+    case "max":
+        return formatNumber(stats.Max, precision)
+    case "avg":
+        return formatNumber(stats.Average, precision)
+    case "sum":
+        return formatNumber(stats.Sum, precision)
+    case "count":
+        return fmt.Sprintf("%d", stats.Count)
+    case "median":
+        return formatNumber(stats.Median, precision)
+    }
+    return ""
+}
+
 // 分组和聚合
-func groupAndAggregate(rows chan DataRow, groupBy string, aggFields []string) []DataRow {
+func groupAndAggregate(rows <-chan DataRow, groupBy []string, specs []AggSpec, precision int) []DataRow {
     groups := make(map[string][]DataRow)
+    var keys []string // 按首次出现的顺序记录分组
     
     // 按分组字段收集行
     for row := range rows {
-        groupValue := row[groupBy]
-        if _, ok := groups[groupValue]; !ok {
-            groups[groupValue] = make([]DataRow, 0, 100)
+        key := groupKey(row, groupBy)
+        if _, ok := groups[key]; !ok {
+            groups[key] = make([]DataRow, 0, 100)
+            keys = append(keys, key)
         }
-        groups[groupValue] = append(groups[groupValue], row)
+        groups[key] = append(groups[key], row)
     }
     
     // 对每个分组执行聚合计算
     results := make([]DataRow, 0, len(groups))
-    for groupValue, groupRows := range groups {
+    for _, key := range keys {
+        groupRows := groups[key]
         aggregated := make(DataRow)
-        aggregated[groupBy] = groupValue
         
-        // 对每个聚合字段计算统计
-        for _, field := range aggFields {
-            stats := calculateStats(groupRows, field)
-            aggregated[field+"_min"] = fmt.Sprintf("%.2f", stats.Min)
-            aggregated[field+"_max"] = fmt.Sprintf("%.2f", stats.Max)
-            aggregated[field+"_avg"] = fmt.Sprintf("%.2f", stats.Average)
-            aggregated[field+"_sum"] = fmt.Sprintf("%.2f", stats.Sum)
-            aggregated[field+"_count"] = fmt.Sprintf("%d", stats.Count)
-            aggregated[field+"_median"] = fmt.Sprintf("%.2f", stats.Median)
+        // 将每个分组字段写回聚合结果
+        for _, field := range groupBy {
+            aggregated[field] = groupRows[0][field]
+        }
+        
+        // 对每个聚合字段计算请求的统计
+        for _, spec := range specs {
+            stats := calculateStats(groupRows, spec.Field)
+            mode, modeCount := modeValue(groupRows, spec.Field)
+            for _, fn := range spec.Funcs {
+                switch fn {
+                case "mode":
+                    aggregated[spec.Field+"_"+fn] = mode
+                case "mode_count":
+                    aggregated[spec.Field+"_"+fn] = strconv.Itoa(modeCount)
+                default:
+                    aggregated[spec.Field+"_"+fn] = formatStat(stats, fn, precision)
+                }
+            }
         }
         
         results = append(results, aggregated)
@@ -354,6 +1243,24 @@ func groupAndAggregate(rows chan DataRow, groupBy string, aggFields []string) []
     return results
 }
 
+// 计算出现次数最多的非空值及其次数，次数相同时取字典序最小的值
+func modeValue(rows []DataRow, field string) (string, int) {
+    counts := make(map[string]int)
+    for _, row := range rows {
+        if val := row[field]; val != "" {
+            counts[val]++
+        }
+    }
+    
+    mode, modeCount := "", 0
+    for val, count := range counts {
+        if count > modeCount || (count == modeCount && val < mode) {
+            mode, modeCount = val, count
+        }
+    }
+    return mode, modeCount
+}
+
 // 计算统计值
 func calculateStats(rows []DataRow, field string) Stats {
     var values []float64
@@ -408,46 +1315,117 @@ func calculateStats(rows []DataRow, field string) Stats {
     }
 }
 
-// 对结果进行排序
-func sortResults(results []DataRow, sortBy string, descending bool) {
-    sort.Slice(results, func(i, j int) bool {
-        // 尝试作为数值比较
-        a, aErr := strconv.ParseFloat(results[i][sortBy], 64)
-        b, bErr := strconv.ParseFloat(results[j][sortBy], 64)
+// 解析排序规则，如 "price:desc,name:asc"，未指定方向的字段使用 defaultDesc
+func parseSortSpec(spec string, defaultDesc bool) []SortKey {
+    var keys []SortKey
+    for _, part := range strings.Split(spec, ",") {
+        part = strings.TrimSpace(part)
+        if part == "" {
+            continue
+        }
         
-        if aErr == nil && bErr == nil {
-            if descending {
-                return a > b
+        key := SortKey{Field: part, Descending: defaultDesc}
+        if idx := strings.LastIndex(part, ":"); idx >= 0 {
+            switch strings.ToLower(part[idx+1:]) {
+            case "desc":
+                key = SortKey{Field: part[:idx], Descending: true}
+            case "asc":
+                key = SortKey{Field: part[:idx], Descending: false}
             }
-            return a < b
         }
-        
-        // 如果不是数值，按字符串比较
-        if descending {
-            return results[i][sortBy] > results[j][sortBy]
+        keys = append(keys, key)
+    }
+    return keys
+}
+
+// 比较两个字段值：都能解析为数值时按数值比较，否则按字符串比较
+func compareValues(a, b string) int {
+    aNum, aErr := strconv.ParseFloat(a, 64)
+    bNum, bErr := strconv.ParseFloat(b, 64)
+    
+    if aErr == nil && bErr == nil {
+        switch {
+        case aNum < bNum:
+            return -1
+        case aNum > bNum:
+            return 1
         }
-        return results[i][sortBy] < results[j][sortBy]
+        return 0
+    }
+    
+    return strings.Compare(a, b)
+}
+
+// 对结果进行排序，前一个键相等时依次比较后续键
+func sortResults(results []DataRow, keys []SortKey) {
+    sort.SliceStable(results, func(i, j int) bool {
+        for _, key := range keys {
+            cmp := compareValues(results[i][key.Field], results[j][key.Field])
+            if cmp == 0 {
+                continue
+            }
+            if key.Descending {
+                return cmp > 0
+            }
+            return cmp < 0
+        }
+        return false
     })
 }
 
-// 写入结果到输出文件
-func writeResults(outputFile string, results []DataRow, headers []string) error {
+// 输出CSV使用的分隔符
+func (c ProcessConfig) outputComma() rune {
+    if c.OutDelimiter != "" {
+        return []rune(c.OutDelimiter)[0]
+    }
+    return []rune(c.Delimiter)[0]
+}
+
+// 是否可以边处理边输出：NDJSON 且不需要分组、去重、排序或滚动窗口等整体结果
+func (c ProcessConfig) streamable() bool {
+    return c.Format == "ndjson" && len(c.GroupBy) == 0 && len(c.Distinct) == 0 &&
+        len(c.SortKeys) == 0 && len(c.Rolling) == 0
+}
+
+// 打开输出位置，- 表示标准输出，写入完成后调用返回的关闭函数
+func openOutput(outputFile string) (io.Writer, func(), error) {
+    if outputFile == "-" {
+        return os.Stdout, func() {}, nil
+    }
+    
     // 创建输出目录
     outputDir := filepath.Dir(outputFile)
     if outputDir != "." {
         if err := os.MkdirAll(outputDir, 0755); err != nil {
-            return fmt.Errorf("创建输出目录失败: %v", err)
+            return nil, nil, fmt.Errorf("创建输出目录失败: %v", err)
         }
     }
     
     // 创建输出文件
     file, err := os.Create(outputFile)
     if err != nil {
-        return fmt.Errorf("创建输出文件失败: %v", err)
+        return nil, nil, fmt.Errorf("创建输出文件失败: %v", err)
     }
-    defer file.Close()
+    return file, func() { file.Close() }, nil
+}
+
+// 写入结果到输出文件
+func writeResults(config ProcessConfig, results []DataRow, headers []string) error {
+    out, closeOutput, err := openOutput(config.OutputFile)
+    if err != nil {
+        return err
+    }
+    defer closeOutput()
     
-    writer := csv.NewWriter(file)
+    switch config.Format {
+    case "json":
+        return writeJSON(out, results, headers, numericColumns(config), config.JSONIndent)
+    case "ndjson":
+        return writeNDJSON(out, results, headers, numericColumns(config))
+    }
+    
+    writer := csv.NewWriter(out)
+    writer.Comma = config.outputComma()
     defer writer.Flush()
     
     // 写入表头
@@ -469,6 +1447,135 @@ func writeResults(outputFile string, results []DataRow, headers []string) error
     return nil
 }
 
+// 以JSON数组格式写入结果，indent 大于0时按指定空格数缩进
+func writeJSON(w io.Writer, results []DataRow, headers []string, numericCols map[string]bool, indent int) error {
+    var buf bytes.Buffer
+    
+    buf.WriteByte('[')
+    for i, row := range results {
+        if i > 0 {
+            buf.WriteByte(',')
+        }
+        buf.Write(encodeJSONRow(row, headers, numericCols))
+    }
+    buf.WriteByte(']')
+    
+    out := buf.Bytes()
+    if indent > 0 {
+        var indented bytes.Buffer
+        if err := json.Indent(&indented, out, "", strings.Repeat(" ", indent)); err != nil {
+            return fmt.Errorf("格式化JSON失败: %v", err)
+        }
+        out = indented.Bytes()
+    }
+    
+    if _, err := w.Write(append(out, '\n')); err != nil {
+        return fmt.Errorf("写入JSON失败: %v", err)
+    }
+    return nil
+}
+
+// 以NDJSON格式逐行写入结果，每行一个JSON对象
+func writeNDJSON(w io.Writer, results []DataRow, headers []string, numericCols map[string]bool) error {
+    bw := bufio.NewWriter(w)
+    
+    for _, row := range results {
+        bw.Write(encodeJSONRow(row, headers, numericCols))
+        if err := bw.WriteByte('\n'); err != nil {
+            return fmt.Errorf("写入NDJSON失败: %v", err)
+        }
+    }
+    
+    if err := bw.Flush(); err != nil {
+        return fmt.Errorf("写入NDJSON失败: %v", err)
+    }
+    return nil
+}
+
+// 处理完一行即写入一行，不等待全部结果；limit 大于0时只写入前 limit 行
+func (s *ndjsonStream) write(rows <-chan DataRow, headers []string, numericCols map[string]bool, limit int) error {
+    bw := bufio.NewWriter(s.w)
+    
+    var err error
+    for row := range rows {
+        // 达到数量限制或写入失败后只读取剩余的行，让其他协程正常结束
+        if err != nil || (limit > 0 && s.rows >= limit) {
+            continue
+        }
+        bw.Write(encodeJSONRow(row, headers, numericCols))
+        if err = bw.WriteByte('\n'); err == nil {
+            s.rows++
+        }
+    }
+    
+    if err == nil {
+        err = bw.Flush()
+    }
+    if err != nil {
+        return fmt.Errorf("写入NDJSON失败: %v", err)
+    }
+    return nil
+}
+
+// 将一行数据编码为JSON对象，键的顺序与表头一致
+func encodeJSONRow(row DataRow, headers []string, numericCols map[string]bool) []byte {
+    var buf bytes.Buffer
+    
+    buf.WriteByte('{')
+    for i, header := range headers {
+        if i > 0 {
+            buf.WriteByte(',')
+        }
+        key, _ := json.Marshal(header)
+        buf.Write(key)
+        buf.WriteByte(':')
+        
+        // 数值列在能正确解析时保持为JSON数字
+        val := row[header]
+        if numericCols[header] && isJSONNumber(val) {
+            buf.WriteString(val)
+        } else {
+            quoted, _ := json.Marshal(val)
+            buf.Write(quoted)
+        }
+    }
+    buf.WriteByte('}')
+    
+    return buf.Bytes()
+}
+
+// 返回需要按数值输出的列：聚合字段及其统计列，以及滚动窗口列
+func numericColumns(config ProcessConfig) map[string]bool {
+    cols := make(map[string]bool)
+    if len(config.GroupBy) > 0 {
+        for _, spec := range config.aggSpecs() {
+            for _, fn := range spec.Funcs {
+                // 众数保持原始字符串
+                if fn != "mode" {
+                    cols[spec.Field+"_"+fn] = true
+                }
+            }
+        }
+    } else {
+        for _, field := range config.AggFields {
+            cols[field] = true
+        }
+    }
+    for _, spec := range config.Rolling {
+        cols[spec.column()] = true
+    }
+    return cols
+}
+
+// 判断字符串是否是合法的JSON数字
+func isJSONNumber(val string) bool {
+    if _, err := strconv.ParseFloat(val, 64); err != nil {
+        return false
+    }
+    // ParseFloat 接受 NaN、Inf、十六进制等JSON不支持的写法
+    return json.Valid([]byte(val))
+}
+
 // Utility functions
 func min(a, b int64) int64 {
     if a < b {
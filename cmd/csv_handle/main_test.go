@@ -0,0 +1,965 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 测试JSON输出的缩进设置
+func TestWriteJSONIndent(t *testing.T) {
+    results := []DataRow{
+        {"name": "apple", "price": "1.50"},
+        {"name": "pear", "price": "2.00"},
+    }
+    headers := []string{"name", "price"}
+    numericCols := map[string]bool{"price": true}
+
+    // 紧凑格式不应包含任何空白字符
+    var compact bytes.Buffer
+    if err := writeJSON(&compact, results, headers, numericCols, 0); err != nil {
+        t.Fatalf("写入紧凑JSON失败: %v", err)
+    }
+    compactOut := strings.TrimSuffix(compact.String(), "\n")
+    if strings.ContainsAny(compactOut, " \t\n") {
+        t.Errorf("紧凑格式不应包含空白字符，得到 %q", compactOut)
+    }
+    expected := `[{"name":"apple","price":1.50},{"name":"pear","price":2.00}]`
+    if compactOut != expected {
+        t.Errorf("紧凑JSON不匹配，期望 %s，得到 %s", expected, compactOut)
+    }
+
+    // 缩进格式应使用指定数量的空格
+    var indented bytes.Buffer
+    if err := writeJSON(&indented, results, headers, numericCols, 4); err != nil {
+        t.Fatalf("写入缩进JSON失败: %v", err)
+    }
+    lines := strings.Split(strings.TrimSuffix(indented.String(), "\n"), "\n")
+    if len(lines) < 2 {
+        t.Fatalf("缩进格式应包含多行，得到 %q", indented.String())
+    }
+    if !strings.HasPrefix(lines[1], "    {") || strings.HasPrefix(lines[1], "     ") {
+        t.Errorf("第一层应缩进4个空格，得到 %q", lines[1])
+    }
+    if !strings.HasPrefix(lines[2], "        \"name\"") {
+        t.Errorf("第二层应缩进8个空格，得到 %q", lines[2])
+    }
+}
+
+// 测试引号内包含分隔符的字段不会被拆分
+func TestProcessCSVQuotedFields(t *testing.T) {
+    tempDir := t.TempDir()
+    inputFile := filepath.Join(tempDir, "quoted.csv")
+    content := "name,city,age\n" +
+        "\"Smith, John\",London,42\n" +
+        "Alice,\"Paris, France\",30\n" +
+        "Bob,Berlin,25\n"
+    if err := os.WriteFile(inputFile, []byte(content), 0644); err != nil {
+        t.Fatalf("创建测试文件失败: %v", err)
+    }
+
+    config := ProcessConfig{
+        InputFile:  inputFile,
+        Delimiter:  ",",
+        NumWorkers: 2,
+    }
+    results, headers, err := processCSV(config)
+    if err != nil {
+        t.Fatalf("处理CSV失败: %v", err)
+    }
+
+    if len(headers) != 3 {
+        t.Fatalf("应该有3个表头，得到 %v", headers)
+    }
+    if len(results) != 3 {
+        t.Fatalf("应该读取3行数据，但实际得到 %d 行", len(results))
+    }
+
+    found := make(map[string]DataRow)
+    for _, row := range results {
+        found[row["name"]] = row
+    }
+    if row, ok := found["Smith, John"]; !ok || row["city"] != "London" || row["age"] != "42" {
+        t.Errorf("带逗号的姓名字段应保持完整，得到 %v", results)
+    }
+    if row, ok := found["Alice"]; !ok || row["city"] != "Paris, France" {
+        t.Errorf("带逗号的城市字段应保持完整，得到 %v", results)
+    }
+}
+
+// 测试从标准输入读取并写入标准输出
+func TestStdinStdout(t *testing.T) {
+    // 用管道替换标准输入
+    inR, inW, err := os.Pipe()
+    if err != nil {
+        t.Fatalf("创建输入管道失败: %v", err)
+    }
+    go func() {
+        inW.WriteString("name,price\napple,1.5\npear,2\n")
+        inW.Close()
+    }()
+
+    // 用管道捕获标准输出
+    outR, outW, err := os.Pipe()
+    if err != nil {
+        t.Fatalf("创建输出管道失败: %v", err)
+    }
+
+    oldStdin, oldStdout := os.Stdin, os.Stdout
+    os.Stdin, os.Stdout = inR, outW
+    defer func() {
+        os.Stdin, os.Stdout = oldStdin, oldStdout
+    }()
+
+    config := ProcessConfig{
+        InputFile:  "-",
+        OutputFile: "-",
+        Delimiter:  ",",
+        NumWorkers: 1,
+        SortKeys:   []SortKey{{Field: "name"}},
+    }
+    results, headers, err := processCSV(config)
+    if err != nil {
+        t.Fatalf("处理标准输入失败: %v", err)
+    }
+    if err := writeResults(config, results, headers); err != nil {
+        t.Fatalf("写入标准输出失败: %v", err)
+    }
+    outW.Close()
+
+    output, err := io.ReadAll(outR)
+    if err != nil {
+        t.Fatalf("读取标准输出失败: %v", err)
+    }
+
+    expected := "name,price\napple,1.5\npear,2\n"
+    if string(output) != expected {
+        t.Errorf("标准输出内容不匹配，期望 %q，得到 %q", expected, string(output))
+    }
+}
+
+// 测试读取逗号分隔的输入并输出制表符分隔的结果
+func TestWriteResultsOutDelimiter(t *testing.T) {
+    tempDir := t.TempDir()
+    inputFile := filepath.Join(tempDir, "input.csv")
+    outputFile := filepath.Join(tempDir, "output.tsv")
+    if err := os.WriteFile(inputFile, []byte("name,note\napple,\"red, sweet\"\npear,green\n"), 0644); err != nil {
+        t.Fatalf("写入测试文件失败: %v", err)
+    }
+
+    config := ProcessConfig{
+        InputFile:    inputFile,
+        OutputFile:   outputFile,
+        Delimiter:    ",",
+        OutDelimiter: "\t",
+        NumWorkers:   1,
+        SortKeys:     []SortKey{{Field: "name"}},
+    }
+    results, headers, err := processCSV(config)
+    if err != nil {
+        t.Fatalf("处理CSV失败: %v", err)
+    }
+    if err := writeResults(config, results, headers); err != nil {
+        t.Fatalf("写入结果失败: %v", err)
+    }
+
+    output, err := os.ReadFile(outputFile)
+    if err != nil {
+        t.Fatalf("读取输出文件失败: %v", err)
+    }
+    expected := "name\tnote\napple\tred, sweet\npear\tgreen\n"
+    if string(output) != expected {
+        t.Errorf("输出内容不匹配，期望 %q，得到 %q", expected, string(output))
+    }
+
+    // 未指定时沿用输入分隔符
+    if comma := (ProcessConfig{Delimiter: ";"}).outputComma(); comma != ';' {
+        t.Errorf("未指定 OutDelimiter 时应使用输入分隔符，得到 %q", comma)
+    }
+}
+
+// 测试去重模式下重复的列组合只保留一行
+func TestProcessCSVDistinct(t *testing.T) {
+    tempDir := t.TempDir()
+    inputFile := filepath.Join(tempDir, "distinct.csv")
+    content := "region,product,price\n" +
+        "north,apple,1\n" +
+        "north,apple,2\n" +
+        "south,apple,3\n" +
+        "north,pear,4\n" +
+        "south,apple,5\n" +
+        "east,pear,6\n"
+    if err := os.WriteFile(inputFile, []byte(content), 0644); err != nil {
+        t.Fatalf("创建测试文件失败: %v", err)
+    }
+
+    config := ProcessConfig{
+        InputFile:  inputFile,
+        Delimiter:  ",",
+        NumWorkers: 1, // 单个工作协程保证处理顺序与输入一致
+        Distinct:   []string{"region", "product"},
+        FilterExpr: "product=apple",
+    }
+    results, headers, err := processCSV(config)
+    if err != nil {
+        t.Fatalf("处理CSV失败: %v", err)
+    }
+
+    if strings.Join(headers, ",") != "region,product" {
+        t.Errorf("表头应为去重列，得到 %v", headers)
+    }
+
+    expected := []string{"north|apple", "south|apple"}
+    if len(results) != len(expected) {
+        t.Fatalf("应该得到 %d 个不重复组合，但实际得到 %d 个: %v", len(expected), len(results), results)
+    }
+    for i, row := range results {
+        got := row["region"] + "|" + row["product"]
+        if got != expected[i] {
+            t.Errorf("第 %d 行应为 %s，得到 %s", i+1, expected[i], got)
+        }
+        if _, ok := row["price"]; ok {
+            t.Errorf("去重结果不应包含未选择的列: %v", row)
+        }
+    }
+}
+
+// 测试重复表头被重命名后两列数据都保留
+func TestProcessCSVDuplicateHeaders(t *testing.T) {
+    tempDir := t.TempDir()
+    inputFile := filepath.Join(tempDir, "dup.csv")
+    content := "id,name,id\n" +
+        "1,apple,A1\n" +
+        "2,pear,B2\n"
+    if err := os.WriteFile(inputFile, []byte(content), 0644); err != nil {
+        t.Fatalf("创建测试文件失败: %v", err)
+    }
+
+    config := ProcessConfig{
+        InputFile:  inputFile,
+        Delimiter:  ",",
+        NumWorkers: 1,
+        SortKeys:   []SortKey{{Field: "id"}},
+    }
+    results, headers, err := processCSV(config)
+    if err != nil {
+        t.Fatalf("处理CSV失败: %v", err)
+    }
+
+    if strings.Join(headers, ",") != "id,name,id_2" {
+        t.Fatalf("重复表头应重命名为 id,name,id_2，得到 %v", headers)
+    }
+    if len(results) != 2 {
+        t.Fatalf("应该读取2行数据，但实际得到 %d 行", len(results))
+    }
+    if results[0]["id"] != "1" || results[0]["id_2"] != "A1" {
+        t.Errorf("两个 id 列都应保留，得到 %v", results[0])
+    }
+    if results[1]["id"] != "2" || results[1]["id_2"] != "B2" {
+        t.Errorf("两个 id 列都应保留，得到 %v", results[1])
+    }
+
+    // 严格模式下报错
+    config.Strict = true
+    if _, _, err := processCSV(config); err == nil {
+        t.Errorf("严格模式下重复表头应该报错")
+    }
+}
+
+// 测试按字段指定聚合函数时只输出请求的统计列
+func TestProcessCSVGroupAgg(t *testing.T) {
+    tempDir := t.TempDir()
+    inputFile := filepath.Join(tempDir, "agg.csv")
+    content := "region,id,revenue,sessions\n" +
+        "north,1,100,10\n" +
+        "north,2,50,20\n" +
+        "south,3,30,5\n"
+    if err := os.WriteFile(inputFile, []byte(content), 0644); err != nil {
+        t.Fatalf("创建测试文件失败: %v", err)
+    }
+
+    specs, err := parseAggSpecs("revenue:sum,sessions:avg,id:count")
+    if err != nil {
+        t.Fatalf("解析聚合规则失败: %v", err)
+    }
+    config := ProcessConfig{
+        InputFile:  inputFile,
+        Delimiter:  ",",
+        NumWorkers: 2,
+        GroupBy:    []string{"region"},
+        AggFields:  []string{"revenue", "sessions", "id"},
+        AggSpecs:   specs,
+        SortKeys:   []SortKey{{Field: "region"}},
+        Precision:  2,
+    }
+    results, headers, err := processCSV(config)
+    if err != nil {
+        t.Fatalf("处理CSV失败: %v", err)
+    }
+
+    expectedHeaders := "region,revenue_sum,sessions_avg,id_count"
+    if strings.Join(headers, ",") != expectedHeaders {
+        t.Errorf("表头应为 %s，得到 %s", expectedHeaders, strings.Join(headers, ","))
+    }
+    if len(results) != 2 {
+        t.Fatalf("应该有2个分组，得到 %d 个", len(results))
+    }
+
+    north := results[0]
+    if north["revenue_sum"] != "150.00" || north["sessions_avg"] != "15.00" || north["id_count"] != "2" {
+        t.Errorf("north 分组的聚合结果不正确: %v", north)
+    }
+    if _, ok := north["revenue_min"]; ok {
+        t.Errorf("未请求的统计列不应出现: %v", north)
+    }
+
+    if _, err := parseAggSpecs("revenue:total"); err == nil {
+        t.Errorf("不支持的聚合函数应该报错")
+    }
+}
+
+// 测试小数位数设置控制聚合结果的输出精度
+func TestProcessCSVPrecision(t *testing.T) {
+    tempDir := t.TempDir()
+    inputFile := filepath.Join(tempDir, "precision.csv")
+    content := "group,value\n" +
+        "a,1\n" +
+        "a,2\n" +
+        "a,2\n"
+    if err := os.WriteFile(inputFile, []byte(content), 0644); err != nil {
+        t.Fatalf("创建测试文件失败: %v", err)
+    }
+
+    testCases := []struct {
+        precision int
+        avg       string
+        sum       string
+    }{
+        {0, "2", "5"},
+        {2, "1.67", "5.00"},
+        {4, "1.6667", "5.0000"},
+    }
+
+    for _, tc := range testCases {
+        config := ProcessConfig{
+            InputFile:  inputFile,
+            Delimiter:  ",",
+            NumWorkers: 1,
+            GroupBy:    []string{"group"},
+            AggFields:  []string{"value"},
+            Precision:  tc.precision,
+        }
+        results, _, err := processCSV(config)
+        if err != nil {
+            t.Fatalf("处理CSV失败: %v", err)
+        }
+        if len(results) != 1 {
+            t.Fatalf("应该有1个分组，得到 %d 个", len(results))
+        }
+
+        row := results[0]
+        if row["value_avg"] != tc.avg || row["value_sum"] != tc.sum {
+            t.Errorf("精度 %d: 平均值/总和应为 %s/%s，得到 %s/%s",
+                tc.precision, tc.avg, tc.sum, row["value_avg"], row["value_sum"])
+        }
+        if row["value_count"] != "3" {
+            t.Errorf("精度 %d: 计数应始终为整数 3，得到 %s", tc.precision, row["value_count"])
+        }
+    }
+}
+
+// 测试按多列组合键关联两个文件
+func TestProcessCSVJoinCompositeKey(t *testing.T) {
+    tempDir := t.TempDir()
+    inputFile := filepath.Join(tempDir, "sales.csv")
+    joinFile := filepath.Join(tempDir, "targets.csv")
+    sales := "year,region,sales\n" +
+        "2023,north,100\n" +
+        "2023,south,200\n" +
+        "2024,north,300\n" +
+        "2024,east,400\n"
+    targets := "region,year,target\n" +
+        "north,2023,110\n" +
+        "south,2023,190\n" +
+        "north,2024,320\n" +
+        "south,2024,250\n"
+    if err := os.WriteFile(inputFile, []byte(sales), 0644); err != nil {
+        t.Fatalf("创建测试文件失败: %v", err)
+    }
+    if err := os.WriteFile(joinFile, []byte(targets), 0644); err != nil {
+        t.Fatalf("创建关联文件失败: %v", err)
+    }
+
+    config := ProcessConfig{
+        InputFile:  inputFile,
+        Delimiter:  ",",
+        NumWorkers: 2,
+        SortKeys:   []SortKey{{Field: "sales"}},
+        JoinFile:   joinFile,
+        JoinKeys:   []string{"year", "region"},
+    }
+    results, headers, err := processCSV(config)
+    if err != nil {
+        t.Fatalf("处理CSV失败: %v", err)
+    }
+
+    if strings.Join(headers, ",") != "year,region,sales,target" {
+        t.Errorf("关联后的表头不正确: %v", headers)
+    }
+
+    // 2024,east 在关联文件中没有匹配，2024,south 只存在于关联文件
+    expected := [][]string{
+        {"2023", "north", "110"},
+        {"2023", "south", "190"},
+        {"2024", "north", "320"},
+    }
+    if len(results) != len(expected) {
+        t.Fatalf("应该关联到 %d 行，得到 %d 行: %v", len(expected), len(results), results)
+    }
+    for i, want := range expected {
+        row := results[i]
+        if row["year"] != want[0] || row["region"] != want[1] || row["target"] != want[2] {
+            t.Errorf("第 %d 行应为 %v，得到 %v", i+1, want, row)
+        }
+    }
+
+    // 关联键不存在时报错
+    config.JoinKeys = []string{"year", "country"}
+    if _, _, err := processCSV(config); err == nil {
+        t.Errorf("关联键不存在时应该报错")
+    }
+}
+
+// 测试CPU和内存分析文件被创建且非空
+func TestProfiles(t *testing.T) {
+    tempDir := t.TempDir()
+    inputFile := filepath.Join(tempDir, "input.csv")
+    content := "group,value\n"
+    for i := 0; i < 1000; i++ {
+        content += "a,1\nb,2\n"
+    }
+    if err := os.WriteFile(inputFile, []byte(content), 0644); err != nil {
+        t.Fatalf("创建测试文件失败: %v", err)
+    }
+
+    cpuFile := filepath.Join(tempDir, "cpu.prof")
+    memFile := filepath.Join(tempDir, "mem.prof")
+
+    stop, err := startCPUProfile(cpuFile)
+    if err != nil {
+        t.Fatalf("启动CPU分析失败: %v", err)
+    }
+    _, _, err = processCSV(ProcessConfig{
+        InputFile:  inputFile,
+        Delimiter:  ",",
+        NumWorkers: 2,
+        GroupBy:    []string{"group"},
+        AggFields:  []string{"value"},
+    })
+    stop()
+    if err != nil {
+        t.Fatalf("处理CSV失败: %v", err)
+    }
+    if err := writeHeapProfile(memFile); err != nil {
+        t.Fatalf("写入内存分析失败: %v", err)
+    }
+
+    for _, path := range []string{cpuFile, memFile} {
+        info, err := os.Stat(path)
+        if err != nil {
+            t.Fatalf("分析文件未创建 %s: %v", path, err)
+        }
+        if info.Size() == 0 {
+            t.Errorf("分析文件 %s 不应为空", filepath.Base(path))
+        }
+    }
+}
+
+// 测试根据多数值推断列类型
+func TestInferColumnTypes(t *testing.T) {
+    tempDir := t.TempDir()
+    inputFile := filepath.Join(tempDir, "mixed.csv")
+    content := "id,price,active,created,name,score,note\n" +
+        "1,9.99,true,2024-01-02,apple,10,\n" +
+        "2,12,false,2024-02-03,banana,20,\n" +
+        "3,3.5,TRUE,2024/03/04,cherry,N/A,\n" +
+        "4,7.25,false,2024-04-05 10:00:00,date,40,\n"
+    if err := os.WriteFile(inputFile, []byte(content), 0644); err != nil {
+        t.Fatalf("创建测试文件失败: %v", err)
+    }
+
+    results, headers, err := processCSV(ProcessConfig{InputFile: inputFile, Delimiter: ",", NumWorkers: 1})
+    if err != nil {
+        t.Fatalf("处理CSV失败: %v", err)
+    }
+
+    types := inferColumnTypes(results, headers, typeSampleRows)
+    expected := map[string]string{
+        "id":      "int",
+        "price":   "float",
+        "active":  "bool",
+        "created": "date",
+        "name":    "string",
+        "score":   "int", // 少数无法解析的值不影响多数类型
+        "note":    "string",
+    }
+    for col, want := range expected {
+        if types[col] != want {
+            t.Errorf("列 %s 的类型应为 %s，得到 %s", col, want, types[col])
+        }
+    }
+
+    typesFile := filepath.Join(tempDir, "types.csv")
+    if err := writeColumnTypes(typesFile, headers, types); err != nil {
+        t.Fatalf("写入列类型失败: %v", err)
+    }
+    data, err := os.ReadFile(typesFile)
+    if err != nil {
+        t.Fatalf("读取列类型失败: %v", err)
+    }
+    if !strings.HasPrefix(string(data), "column,type\nid,int\nprice,float\n") {
+        t.Errorf("列类型文件内容不正确:\n%s", data)
+    }
+}
+
+// 测试按多列组合分组，并发处理较多的行时所有行都能汇总完成
+func TestProcessCSVGroupByMultipleColumns(t *testing.T) {
+    tempDir := t.TempDir()
+    inputFile := filepath.Join(tempDir, "sales.csv")
+    var content strings.Builder
+    content.WriteString("region,product,amount\n")
+    // 每个组合重复 50 次，行数多于通道缓冲，覆盖并发处理的汇总路径
+    for i := 0; i < 50; i++ {
+        content.WriteString("north,apple,1\n")
+        content.WriteString("north,pear,2\n")
+        content.WriteString("south,apple,3\n")
+    }
+    if err := os.WriteFile(inputFile, []byte(content.String()), 0644); err != nil {
+        t.Fatalf("创建测试文件失败: %v", err)
+    }
+
+    specs, err := parseAggSpecs("amount:sum,amount:count")
+    if err != nil {
+        t.Fatalf("解析聚合规则失败: %v", err)
+    }
+    config := ProcessConfig{
+        InputFile:  inputFile,
+        Delimiter:  ",",
+        NumWorkers: 4,
+        GroupBy:    []string{"region", "product"},
+        AggFields:  []string{"amount"},
+        AggSpecs:   specs,
+        SortKeys:   []SortKey{{Field: "region"}, {Field: "product"}},
+    }
+    results, headers, err := processCSV(config)
+    if err != nil {
+        t.Fatalf("处理CSV失败: %v", err)
+    }
+
+    if strings.Join(headers, ",") != "region,product,amount_sum,amount_count" {
+        t.Errorf("表头不正确: %v", headers)
+    }
+    expected := [][]string{
+        {"north", "apple", "50", "50"},
+        {"north", "pear", "100", "50"},
+        {"south", "apple", "150", "50"},
+    }
+    if len(results) != len(expected) {
+        t.Fatalf("应该有 %d 个分组，得到 %d 个: %v", len(expected), len(results), results)
+    }
+    for i, want := range expected {
+        row := results[i]
+        got := []string{row["region"], row["product"], row["amount_sum"], row["amount_count"]}
+        if strings.Join(got, ",") != strings.Join(want, ",") {
+            t.Errorf("第 %d 个分组应为 %v，得到 %v", i+1, want, got)
+        }
+    }
+}
+
+// 测试按分组计算众数，次数相同时取字典序最小的值
+func TestProcessCSVGroupMode(t *testing.T) {
+    tempDir := t.TempDir()
+    inputFile := filepath.Join(tempDir, "mode.csv")
+    content := "region,city\n" +
+        "north,beijing\n" +
+        "north,tianjin\n" +
+        "north,beijing\n" +
+        "north,\n" +
+        "south,shenzhen\n" +
+        "south,guangzhou\n"
+    if err := os.WriteFile(inputFile, []byte(content), 0644); err != nil {
+        t.Fatalf("创建测试文件失败: %v", err)
+    }
+
+    specs, err := parseAggSpecs("city:mode,city:mode_count")
+    if err != nil {
+        t.Fatalf("解析聚合规则失败: %v", err)
+    }
+    config := ProcessConfig{
+        InputFile:  inputFile,
+        Delimiter:  ",",
+        NumWorkers: 1,
+        GroupBy:    []string{"region"},
+        AggFields:  []string{"city"},
+        AggSpecs:   specs,
+        SortKeys:   []SortKey{{Field: "region"}},
+    }
+    results, headers, err := processCSV(config)
+    if err != nil {
+        t.Fatalf("处理CSV失败: %v", err)
+    }
+
+    if strings.Join(headers, ",") != "region,city_mode,city_mode_count" {
+        t.Errorf("表头不正确: %v", headers)
+    }
+    if len(results) != 2 {
+        t.Fatalf("应该有2个分组，得到 %d 个", len(results))
+    }
+
+    // 明显的多数值
+    if results[0]["city_mode"] != "beijing" || results[0]["city_mode_count"] != "2" {
+        t.Errorf("north 的众数应为 beijing (2次)，得到 %s (%s次)", results[0]["city_mode"], results[0]["city_mode_count"])
+    }
+    // 次数相同时取字典序最小的值
+    if results[1]["city_mode"] != "guangzhou" || results[1]["city_mode_count"] != "1" {
+        t.Errorf("south 的众数应为 guangzhou (1次)，得到 %s (%s次)", results[1]["city_mode"], results[1]["city_mode_count"])
+    }
+}
+
+// 测试排序后的滚动平均，窗口开头按已有行计算，指定分组时每组单独计算
+func TestProcessCSVRolling(t *testing.T) {
+    tempDir := t.TempDir()
+    inputFile := filepath.Join(tempDir, "series.csv")
+    content := "day,city,value\n" +
+        "3,bj,30\n" +
+        "1,bj,10\n" +
+        "2,sh,5\n" +
+        "2,bj,20\n" +
+        "4,bj,n/a\n" +
+        "5,bj,60\n" +
+        "1,sh,1\n"
+    if err := os.WriteFile(inputFile, []byte(content), 0644); err != nil {
+        t.Fatalf("写入测试文件失败: %v", err)
+    }
+
+    specs, err := parseRollingSpecs("value:3:avg")
+    if err != nil {
+        t.Fatalf("解析滚动窗口规则失败: %v", err)
+    }
+    config := ProcessConfig{
+        InputFile:    inputFile,
+        Delimiter:    ",",
+        NumWorkers:   2,
+        SortKeys:     []SortKey{{Field: "city"}, {Field: "day"}},
+        Rolling:      specs,
+        RollingGroup: []string{"city"},
+        Precision:    2,
+    }
+    results, headers, err := processCSV(config)
+    if err != nil {
+        t.Fatalf("处理CSV失败: %v", err)
+    }
+    if headers[len(headers)-1] != "value_rolling_avg_3" {
+        t.Fatalf("应追加滚动平均列，得到表头 %v", headers)
+    }
+
+    // bj: 10, 15 (不足3行), 20, 空 (非数值), 36.67 (20,30,60)；sh 单独计算
+    expected := []string{"10.00", "15.00", "20.00", "", "36.67", "1.00", "3.00"}
+    for i, row := range results {
+        if got := row["value_rolling_avg_3"]; got != expected[i] {
+            t.Errorf("第 %d 行 (%s, 第 %s 天) 滚动平均应为 %q，得到 %q", i+1, row["city"], row["day"], expected[i], got)
+        }
+    }
+
+    for _, bad := range []string{"value:0:avg", "value:3:median", "value:avg"} {
+        if _, err := parseRollingSpecs(bad); err == nil {
+            t.Errorf("规则 %q 应报错", bad)
+        }
+    }
+}
+
+// 测试跳过表头之前的说明行，说明行不需要符合CSV格式
+func TestProcessCSVSkipRows(t *testing.T) {
+    tempDir := t.TempDir()
+    inputFile := filepath.Join(tempDir, "export.csv")
+    content := "销售报表 \"2024年\n" +
+        "导出时间: 2024-07-28\n" +
+        "name,price\n" +
+        "apple,1.5\n" +
+        "pear,2\n"
+    if err := os.WriteFile(inputFile, []byte(content), 0644); err != nil {
+        t.Fatalf("写入测试文件失败: %v", err)
+    }
+
+    config := ProcessConfig{
+        InputFile:  inputFile,
+        Delimiter:  ",",
+        NumWorkers: 1,
+        SortKeys:   []SortKey{{Field: "name"}},
+        SkipRows:   2,
+    }
+    results, headers, err := processCSV(config)
+    if err != nil {
+        t.Fatalf("处理CSV失败: %v", err)
+    }
+    if strings.Join(headers, ",") != "name,price" {
+        t.Errorf("表头应为 name,price，得到 %v", headers)
+    }
+    if len(results) != 2 || results[0]["name"] != "apple" || results[1]["price"] != "2" {
+        t.Errorf("数据行不正确: %v", results)
+    }
+
+    config.SkipRows = 10
+    if _, _, err := processCSV(config); err == nil {
+        t.Error("跳过的行数超过文件行数时应报错")
+    }
+}
+
+// 测试NDJSON逐行写入，数值列输出为JSON数字
+func TestProcessCSVStreamNDJSON(t *testing.T) {
+    tempDir := t.TempDir()
+    inputFile := filepath.Join(tempDir, "stream.csv")
+    content := "name,price,code\n" +
+        "apple,1.5,007\n" +
+        "pear,abc,008\n" +
+        "plum,3,009\n"
+    if err := os.WriteFile(inputFile, []byte(content), 0644); err != nil {
+        t.Fatalf("写入测试文件失败: %v", err)
+    }
+
+    var out bytes.Buffer
+    config := ProcessConfig{
+        InputFile:  inputFile,
+        Delimiter:  ",",
+        NumWorkers: 1,
+        AggFields:  []string{"price"},
+        Format:     "ndjson",
+        Precision:  2,
+        Select:     []string{"name", "price", "code"},
+        Limit:      2,
+        Stream:     &ndjsonStream{w: &out},
+    }
+    if !config.streamable() {
+        t.Fatal("不需要整体结果的NDJSON输出应逐行写入")
+    }
+    results, headers, err := processCSV(config)
+    if err != nil {
+        t.Fatalf("处理CSV失败: %v", err)
+    }
+    if len(results) != 0 {
+        t.Errorf("逐行写入时不应收集结果，得到 %d 行", len(results))
+    }
+    if strings.Join(headers, ",") != "name,price,code" {
+        t.Errorf("表头应为 name,price,code，得到 %v", headers)
+    }
+    if config.Stream.rows != 2 {
+        t.Errorf("应写入 %d 行，得到 %d 行", 2, config.Stream.rows)
+    }
+
+    lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+    expected := []string{
+        `{"name":"apple","price":1.50,"code":"007"}`,
+        `{"name":"pear","price":"abc","code":"008"}`,
+    }
+    if strings.Join(lines, "\n") != strings.Join(expected, "\n") {
+        t.Fatalf("NDJSON输出不匹配，期望 %v，得到 %v", expected, lines)
+    }
+
+    // 数值列解码后应为数字，无法解析的值和非数值列保持为字符串
+    var row map[string]interface{}
+    if err := json.Unmarshal([]byte(lines[0]), &row); err != nil {
+        t.Fatalf("解析NDJSON行失败: %v", err)
+    }
+    if price, ok := row["price"].(float64); !ok || price != 1.5 {
+        t.Errorf("price 应为数字 1.5，得到 %#v", row["price"])
+    }
+    if _, ok := row["code"].(string); !ok {
+        t.Errorf("code 不是数值列，应保持为字符串，得到 %#v", row["code"])
+    }
+
+    config.SortKeys = []SortKey{{Field: "name"}}
+    if config.streamable() {
+        t.Error("需要排序时不应逐行写入")
+    }
+}
+
+// 测试读取失败时停止处理并返回错误，而不是当作格式错误的行跳过
+func TestProcessCSVReadError(t *testing.T) {
+    inR, inW, err := os.Pipe()
+    if err != nil {
+        t.Fatalf("创建输入管道失败: %v", err)
+    }
+    defer inW.Close()
+    inW.WriteString("name,price\napple,1.5\n")
+
+    oldStdin := os.Stdin
+    os.Stdin = inR
+    defer func() {
+        os.Stdin = oldStdin
+    }()
+
+    // 读取协程阻塞在管道上时关闭它，之后的每次读取都会失败
+    go func() {
+        time.Sleep(100 * time.Millisecond)
+        inR.Close()
+    }()
+
+    done := make(chan error, 1)
+    go func() {
+        config := ProcessConfig{
+            InputFile:  "-",
+            Delimiter:  ",",
+            NumWorkers: 1,
+        }
+        _, _, err := processCSV(config)
+        done <- err
+    }()
+
+    select {
+    case err := <-done:
+        if err == nil || !strings.Contains(err.Error(), "读取第 2 行数据失败") {
+            t.Errorf("读取失败时应返回第 2 行的错误，得到 %v", err)
+        }
+    case <-time.After(5 * time.Second):
+        t.Fatal("读取失败后处理没有结束")
+    }
+}
+
+// 测试 -select 按给定顺序投影列，并对不存在或重复的列报错
+func TestProcessCSVSelect(t *testing.T) {
+    tempDir := t.TempDir()
+    inputFile := filepath.Join(tempDir, "select.csv")
+    content := "name,city,price\n" +
+        "pear,Paris,2\n" +
+        "apple,London,1.5\n"
+    if err := os.WriteFile(inputFile, []byte(content), 0644); err != nil {
+        t.Fatalf("写入测试文件失败: %v", err)
+    }
+
+    // 排序可以使用未被选择的列
+    config := ProcessConfig{
+        InputFile:  inputFile,
+        Delimiter:  ",",
+        NumWorkers: 1,
+        SortKeys:   []SortKey{{Field: "price"}},
+        Select:     []string{"price", " name"},
+    }
+    results, headers, err := processCSV(config)
+    if err != nil {
+        t.Fatalf("处理CSV失败: %v", err)
+    }
+    if strings.Join(headers, ",") != "price,name" {
+        t.Errorf("表头应为 price,name，得到 %v", headers)
+    }
+    if len(results) != 2 || results[0]["name"] != "apple" {
+        t.Errorf("结果应按 price 排序，得到 %v", results)
+    }
+
+    config.Select = []string{"name", "country"}
+    _, _, err = processCSV(config)
+    if err == nil {
+        t.Fatal("选择不存在的列时应报错")
+    }
+    if !strings.Contains(err.Error(), "选择的列不存在: country") || !strings.Contains(err.Error(), "name, city, price") {
+        t.Errorf("错误信息应包含缺失的列和可用列，得到 %v", err)
+    }
+
+    config.Select = []string{"name", "price", "name"}
+    if _, _, err := processCSV(config); err == nil || !strings.Contains(err.Error(), "选择的列重复: name") {
+        t.Errorf("选择重复的列时应报错，得到 %v", err)
+    }
+}
+
+// 测试多键排序：第一键相同时按第二键排序，各键方向独立
+func TestSortResultsMultiKey(t *testing.T) {
+    keys := parseSortSpec("price:desc,name:asc", false)
+    expectedKeys := []SortKey{{Field: "price", Descending: true}, {Field: "name"}}
+    if len(keys) != len(expectedKeys) || keys[0] != expectedKeys[0] || keys[1] != expectedKeys[1] {
+        t.Fatalf("排序规则解析错误，期望 %v，得到 %v", expectedKeys, keys)
+    }
+
+    results := []DataRow{
+        {"name": "pear", "price": "2"},
+        {"name": "apple", "price": "10"},
+        {"name": "plum", "price": "2"},
+        {"name": "fig", "price": "2.0"},
+        {"name": "kiwi", "price": "1"},
+    }
+    sortResults(results, keys)
+
+    // price 按数值降序，2 与 2.0 相同，按 name 升序
+    expected := []string{"apple", "fig", "pear", "plum", "kiwi"}
+    for i, name := range expected {
+        if results[i]["name"] != name {
+            t.Fatalf("第 %d 行应为 %s，得到 %v", i+1, name, results)
+        }
+    }
+
+    // 反转方向后，价格相同的行按 name 降序
+    sortResults(results, parseSortSpec("price,name:desc", false))
+    expected = []string{"kiwi", "plum", "pear", "fig", "apple"}
+    for i, name := range expected {
+        if results[i]["name"] != name {
+            t.Fatalf("第 %d 行应为 %s，得到 %v", i+1, name, results)
+        }
+    }
+}
+
+// 测试跳过格式错误和字段数不匹配的行，并写入拒绝文件和汇总信息
+func TestProcessCSVRejects(t *testing.T) {
+    tempDir := t.TempDir()
+    inputFile := filepath.Join(tempDir, "dirty.csv")
+    rejectsFile := filepath.Join(tempDir, "rejects.csv")
+    content := "name,price\n" +
+        "apple,1.5\n" +
+        "ap\"ple,3\n" +
+        "pear,2,extra\n" +
+        "plum,4\n"
+    if err := os.WriteFile(inputFile, []byte(content), 0644); err != nil {
+        t.Fatalf("写入测试文件失败: %v", err)
+    }
+
+    var summary bytes.Buffer
+    oldProgress := progress
+    progress = &summary
+    defer func() {
+        progress = oldProgress
+    }()
+
+    config := ProcessConfig{
+        InputFile:   inputFile,
+        Delimiter:   ",",
+        NumWorkers:  1,
+        SortKeys:    []SortKey{{Field: "name"}},
+        RejectsFile: rejectsFile,
+    }
+    results, _, err := processCSV(config)
+    if err != nil {
+        t.Fatalf("处理CSV失败: %v", err)
+    }
+    if len(results) != 2 || results[0]["name"] != "apple" || results[1]["name"] != "plum" {
+        t.Errorf("应只保留2行有效数据，得到 %v", results)
+    }
+
+    // 格式错误的行无法还原字段，只有字段数不匹配的行写入拒绝文件
+    rejects, err := os.ReadFile(rejectsFile)
+    if err != nil {
+        t.Fatalf("读取拒绝文件失败: %v", err)
+    }
+    expected := "name,price\npear,2,extra\n"
+    if string(rejects) != expected {
+        t.Errorf("拒绝文件内容不匹配，期望 %q，得到 %q", expected, string(rejects))
+    }
+
+    if !strings.Contains(summary.String(), "共读取 4 行数据") {
+        t.Errorf("汇总应包含读取的行数，得到 %q", summary.String())
+    }
+    if !strings.Contains(summary.String(), "跳过 2 行格式错误或字段数不匹配的数据 (示例行号: 3, 4)") {
+        t.Errorf("汇总应包含跳过的行数和行号，得到 %q", summary.String())
+    }
+}
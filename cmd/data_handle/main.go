@@ -2,14 +2,23 @@ package main
 
 import (
     "bufio"
+    "bytes"
+    "encoding/csv"
+    "flag"
     "fmt"
     "io"
     "os"
+    "os/exec"
     "path/filepath"
     "regexp"
+    "sort"
+    "strconv"
     "strings"
     "sync"
     "time"
+    "unicode/utf8"
+
+    "golang.org/x/text/encoding/simplifiedchinese"
 )
 
 // ButtonData 结构体用于表示按钮数据
@@ -23,6 +32,51 @@ type ButtonData struct {
     LineNumber  int    // 行号(从1开始)
     SearchTime  time.Duration // 搜索耗时
     SourceFile  string // 找到按钮值的源文件
+    SourceLine  int    // 按钮值在源文件中的行号(从1开始)，未匹配时为0
+}
+
+// 结果文件中的一列
+type OutputColumn struct {
+    Name  string                   // 列名，同时作为表头
+    Value func(*ButtonData) string // 取该列的值
+}
+
+// 结果文件中可输出的全部列，前8列为原有格式
+var outputColumns = []OutputColumn{
+    {"button", func(d *ButtonData) string { return d.Button }},
+    {"projectcode", func(d *ButtonData) string { return d.ProjectCode }},
+    {"page", func(d *ButtonData) string { return d.Page }},
+    {"按钮值", func(d *ButtonData) string { return d.ButtonValue }},
+    {"页面上按钮的名称", func(d *ButtonData) string { return d.ButtonName }},
+    {"页面名称", func(d *ButtonData) string { return d.PageName }},
+    {"源文件", func(d *ButtonData) string { return filepath.Base(d.SourceFile) }},
+    {"搜索耗时(ms)", func(d *ButtonData) string { return strconv.FormatInt(d.SearchTime.Milliseconds(), 10) }},
+    {"input_line", func(d *ButtonData) string { return strconv.Itoa(d.LineNumber) }},
+    {"source_line", func(d *ButtonData) string {
+        if d.SourceLine == 0 {
+            return ""
+        }
+        return strconv.Itoa(d.SourceLine)
+    }},
+}
+
+// 默认输出的列，与原有结果文件格式一致
+var defaultColumns = []string{"button", "projectcode", "page", "按钮值", "页面上按钮的名称", "页面名称", "源文件", "搜索耗时(ms)"}
+
+// 按页面汇总的按钮处理函数
+type PageRollup struct {
+    Page        string   // 页面路径
+    PageName    string   // 页面名称
+    Buttons     int      // 页面上的按钮数
+    SourceFiles []string // 去重后的源文件
+    Handlers    []string // 去重后的处理函数(按钮名称)
+}
+
+// 演练统计，只解析按钮和收集文件，不搜索文件内容
+type DryRunReport struct {
+    Buttons       int   // 解析到的按钮数
+    Files         int   // 收集到的文件数
+    RelevantFiles []int // 每个按钮的相关文件数，与按钮顺序一致
 }
 
 // 匹配结果的质量分级
@@ -38,6 +92,173 @@ type MatchResult struct {
     Quality   int
     FilePath  string
     ButtonName string // 页面上按钮的名称（函数注释或函数名）
+    Function  string // 匹配行所在的函数
+    LineNumber int   // 匹配行在源文件中的行号(从1开始)
+}
+
+// 搜索选项
+type SearchOptions struct {
+    CaseSensitive  bool                // 区分大小写匹配按钮标识
+    Coverage       *Coverage           // 统计每个文件的匹配次数，为 nil 时不统计
+    SourceEncoding string              // 源文件编码: utf-8, gbk, gb18030
+    FollowDepth    int                 // 处理函数没有注释时沿调用链查找注释的最大深度，0 表示不查找
+    Calls          map[string][]string // 函数名 -> 函数体中调用的函数名
+    Fallbacks      *EncodingFallbacks  // 记录改用回退编码读取的文件，为 nil 时不记录
+    Sources        *SourceCache        // 按路径缓存解码后的源文件，为 nil 时每次重新读取
+    LogFuncs       []string            // 点击日志函数名，调用这些函数的行视为高优先级匹配，为空时使用 defaultLogFuncs
+    ObjectArgOnly  bool                // 只有以 {button: ...} 传参的点击日志调用视为高优先级，与旧版的匹配质量一致
+}
+
+// 默认的点击日志函数名，包括项目中历史遗留的拼写 addOpeartionsClickLog
+var defaultLogFuncs = []string{"addOpeartionsClickLog", "addOperationsClickLog"}
+
+// 实际使用的点击日志函数名
+func (o SearchOptions) logFuncs() []string {
+    if len(o.LogFuncs) > 0 {
+        return o.LogFuncs
+    }
+    return defaultLogFuncs
+}
+
+// 判断代码行是否调用了点击日志函数，与匹配模式一致不区分大小写
+func (o SearchOptions) isClickLogCall(line string) bool {
+    line = strings.ToLower(line)
+    for _, fn := range o.logFuncs() {
+        if strings.Contains(line, strings.ToLower(fn)) {
+            return true
+        }
+    }
+    return false
+}
+
+// 按UTF-8读取时替换字符占非ASCII字符的比例超过该值，视为编码错误并改用GBK重新读取
+const replacementRatioThreshold = 0.3
+
+// 记录改用回退编码读取的源文件，每个文件只输出一次日志
+type EncodingFallbacks struct {
+    mu     sync.Mutex
+    logger *Logger
+    files  map[string]bool
+}
+
+// 新建回退编码记录
+func NewEncodingFallbacks(logger *Logger) *EncodingFallbacks {
+    return &EncodingFallbacks{logger: logger, files: make(map[string]bool)}
+}
+
+// 记录文件读取时的编码回退，ok 表示回退编码是否读取成功
+func (f *EncodingFallbacks) Record(filePath string, encoding string, ok bool) {
+    if f == nil {
+        return
+    }
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    
+    if f.files[filePath] {
+        return
+    }
+    f.files[filePath] = true
+    if ok {
+        f.logger.Infof("文件 %s 按UTF-8读取出现大量替换字符，已改用 %s 读取", filePath, encoding)
+    } else {
+        f.logger.Infof("文件 %s 按UTF-8读取出现大量替换字符，改用 %s 仍无法解码，按UTF-8处理", filePath, encoding)
+    }
+}
+
+// 按路径缓存解码后的源文件内容，同一次运行中每个文件只读取和解码一次
+type SourceCache struct {
+    mu    sync.Mutex
+    files map[string][]byte
+}
+
+// 新建源文件缓存
+func NewSourceCache() *SourceCache {
+    return &SourceCache{files: make(map[string][]byte)}
+}
+
+// 返回缓存的解码内容，缓存为 nil 时总是未命中
+func (c *SourceCache) Get(filePath string) ([]byte, bool) {
+    if c == nil {
+        return nil, false
+    }
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    
+    content, ok := c.files[filePath]
+    return content, ok
+}
+
+// 缓存文件解码后的内容，调用方不能再修改 content
+func (c *SourceCache) Put(filePath string, content []byte) {
+    if c == nil {
+        return
+    }
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    
+    c.files[filePath] = content
+}
+
+// 记录每个被搜索过的源文件产生的匹配次数
+type Coverage struct {
+    mu     sync.Mutex
+    counts map[string]int
+}
+
+// 新建匹配覆盖率统计
+func NewCoverage() *Coverage {
+    return &Coverage{counts: make(map[string]int)}
+}
+
+// 记录一次文件搜索，matched 表示是否产生了匹配
+func (c *Coverage) Record(filePath string, matched bool) {
+    if c == nil {
+        return
+    }
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    
+    if matched {
+        c.counts[filePath]++
+    } else if _, ok := c.counts[filePath]; !ok {
+        c.counts[filePath] = 0
+    }
+}
+
+// 将覆盖率统计写入CSV文件，按匹配次数降序排列
+func (c *Coverage) WriteCSV(path string) error {
+    c.mu.Lock()
+    files := make([]string, 0, len(c.counts))
+    for file := range c.counts {
+        files = append(files, file)
+    }
+    counts := make(map[string]int, len(c.counts))
+    for file, count := range c.counts {
+        counts[file] = count
+    }
+    c.mu.Unlock()
+    
+    sort.Slice(files, func(i, j int) bool {
+        if counts[files[i]] != counts[files[j]] {
+            return counts[files[i]] > counts[files[j]]
+        }
+        return files[i] < files[j]
+    })
+    
+    file, err := os.Create(path)
+    if err != nil {
+        return err
+    }
+    defer file.Close()
+    
+    writer := csv.NewWriter(file)
+    writer.Write([]string{"file", "matches"})
+    for _, f := range files {
+        writer.Write([]string{f, strconv.Itoa(counts[f])})
+    }
+    writer.Flush()
+    
+    return writer.Error()
 }
 
 // 正则表达式为全局变量，避免重复编译
@@ -71,7 +292,116 @@ var (
     excludeRegex = regexp.MustCompile(`(?i)(^\s*</div|^\s*<!--)`)
 )
 
+// 日志级别
+const (
+    LogLevelError = iota // 只输出错误
+    LogLevelInfo         // 输出进度和每个按钮的汇总
+    LogLevelDebug        // 输出每个文件的匹配详情
+)
+
+// 按级别过滤的日志记录器
+type Logger struct {
+    mu      sync.Mutex
+    level   int
+    writers []io.Writer
+}
+
+// 新建日志记录器，日志同时写入所有 writers
+func NewLogger(level int, writers ...io.Writer) *Logger {
+    return &Logger{level: level, writers: writers}
+}
+
+// 解析日志级别名称
+func parseLogLevel(name string) (int, error) {
+    switch strings.ToLower(name) {
+    case "error":
+        return LogLevelError, nil
+    case "info":
+        return LogLevelInfo, nil
+    case "debug":
+        return LogLevelDebug, nil
+    }
+    return 0, fmt.Errorf("无效的日志级别: %s", name)
+}
+
+// 输出不高于当前级别的日志
+func (l *Logger) logf(level int, format string, args ...interface{}) {
+    if level > l.level {
+        return
+    }
+    
+    message := fmt.Sprintf(format, args...)
+    timestamp := time.Now().Format("2006-01-02 15:04:05")
+    logLine := fmt.Sprintf("[%s] %s\n", timestamp, message)
+    
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    for _, w := range l.writers {
+        io.WriteString(w, logLine)
+    }
+}
+
+// 错误日志
+func (l *Logger) Errorf(format string, args ...interface{}) {
+    l.logf(LogLevelError, format, args...)
+}
+
+// 进度日志
+func (l *Logger) Infof(format string, args ...interface{}) {
+    l.logf(LogLevelInfo, format, args...)
+}
+
+// 调试日志
+func (l *Logger) Debugf(format string, args ...interface{}) {
+    l.logf(LogLevelDebug, format, args...)
+}
+
 func main() {
+    // 解析命令行参数
+    gitRange := flag.String("git-range", "", "仅搜索该提交范围内变更的文件(如 base..head)")
+    byPageFile := flag.String("by-page", "", "按页面汇总去重后的源文件和处理函数，写入该文件")
+    coverageFile := flag.String("coverage", "", "输出每个被搜索文件的匹配次数到该CSV文件")
+    sourceEncoding := flag.String("source-encoding", "utf-8", "源文件编码(utf-8, gbk, gb18030)，utf-8 读取出现大量替换字符时自动改用 gbk")
+    caseSensitive := flag.Bool("case-sensitive", false, "区分大小写匹配按钮标识")
+    followImports := flag.Int("follow-imports", 0, "处理函数没有注释时沿调用链到其他文件查找注释的最大深度(0 表示不查找)")
+    columnList := flag.String("columns", strings.Join(defaultColumns, ","), "结果文件输出的列及顺序(逗号分隔)，默认为原有格式")
+    dryRun := flag.Bool("dry-run", false, "只统计按钮数、文件数和每个按钮的相关文件数，不搜索也不写结果文件")
+    logFuncs := flag.String("log-funcs", strings.Join(defaultLogFuncs, ","), "点击日志函数名(逗号分隔)，调用这些函数的行视为高质量匹配")
+    objectArgOnly := flag.Bool("object-arg-only", false, "只有以 {button: ...} 传参的点击日志调用视为高质量匹配，直接以按钮标识为参数的调用为中等质量(与旧版输出一致)")
+    logLevel := flag.String("log-level", "info", "日志级别(error, info, debug)，debug 输出每个文件的匹配详情")
+    flag.Parse()
+
+    level, err := parseLogLevel(*logLevel)
+    if err != nil {
+        fmt.Println(err)
+        flag.Usage()
+        return
+    }
+
+    if _, err := decodeSource(nil, *sourceEncoding); err != nil {
+        fmt.Println(err)
+        flag.Usage()
+        return
+    }
+
+    columns, err := parseColumns(*columnList)
+    if err != nil {
+        fmt.Println(err)
+        flag.Usage()
+        return
+    }
+
+    opts := SearchOptions{
+        CaseSensitive:  *caseSensitive,
+        SourceEncoding: *sourceEncoding,
+        FollowDepth:    *followImports,
+        LogFuncs:       parseLogFuncs(*logFuncs),
+        ObjectArgOnly:  *objectArgOnly,
+    }
+    if *coverageFile != "" {
+        opts.Coverage = NewCoverage()
+    }
+
     // 记录程序开始时间
     startTime := time.Now()
 
@@ -83,35 +413,29 @@ func main() {
     }
     defer logFile.Close()
 
-    // 写入日志函数
-    writeLog := func(format string, args ...interface{}) {
-        message := fmt.Sprintf(format, args...)
-        timestamp := time.Now().Format("2006-01-02 15:04:05")
-        logLine := fmt.Sprintf("[%s] %s\n", timestamp, message)
-        
-        // 同时输出到控制台和日志文件
-        fmt.Print(logLine)
-        logFile.WriteString(logLine)
-    }
+    // 同时输出到控制台和日志文件
+    logger := NewLogger(level, os.Stdout, logFile)
+    opts.Fallbacks = NewEncodingFallbacks(logger)
+    opts.Sources = NewSourceCache()
 
-    writeLog("程序开始执行")
+    logger.Infof("程序开始执行")
 
     // 输入文件路径
     inputFile := "1.txt"
     
     // 默认项目目录，可以通过命令行参数覆盖
     projectDir := `D:\project\cx_project\china_mobile\gitProject\bigclass\src\main\webapp\res\wap\`
-    if len(os.Args) > 1 {
-        projectDir = os.Args[1]
+    if flag.NArg() > 0 {
+        projectDir = flag.Arg(0)
     }
     
-    writeLog("项目目录: %s", projectDir)
-    writeLog("输入文件: %s", inputFile)
+    logger.Infof("项目目录: %s", projectDir)
+    logger.Infof("输入文件: %s", inputFile)
     
     // 打开文件
     file, err := os.Open(inputFile)
     if err != nil {
-        writeLog("打开文件失败: %v", err)
+        logger.Errorf("打开文件失败: %v", err)
         return
     }
     defer file.Close()
@@ -119,31 +443,45 @@ func main() {
     // 读取并解析TSV文件
     buttonDataList, err := parseTsvFile(file)
     if err != nil {
-        writeLog("解析文件失败: %v", err)
+        logger.Errorf("解析文件失败: %v", err)
         return
     }
     
-    writeLog("成功解析 %d 条按钮数据", len(buttonDataList))
+    logger.Infof("成功解析 %d 条按钮数据", len(buttonDataList))
     
     // 预先收集所有HTML和JS文件
     allFiles, err := collectAllFiles(projectDir)
     if err != nil {
-        writeLog("收集文件失败: %v", err)
+        logger.Errorf("收集文件失败: %v", err)
         return
     }
     
-    writeLog("找到 %d 个HTML/JS文件用于搜索", len(allFiles))
+    logger.Infof("找到 %d 个HTML/JS文件用于搜索", len(allFiles))
+    
+    // 只搜索提交范围内变更的文件，函数注释和调用关系仍从全部文件中提取
+    searchFiles := filesToSearch(projectDir, *gitRange, allFiles, logger)
+    
+    // 演练模式到此为止，不读取文件内容
+    if *dryRun {
+        reportDryRun(buttonDataList, searchFiles, logger)
+        return
+    }
     
     // 预先分析文件，提取函数定义和注释
-    functionCommentMap := extractFunctionComments(allFiles, writeLog)
-    writeLog("从文件中提取了 %d 个函数定义及其注释", len(functionCommentMap))
+    functionCommentMap := extractFunctionComments(allFiles, opts, logger)
+    logger.Infof("从文件中提取了 %d 个函数定义及其注释", len(functionCommentMap))
+    
+    if opts.FollowDepth > 0 {
+        opts.Calls = extractFunctionCalls(allFiles, opts, logger)
+        logger.Infof("提取了 %d 个函数的调用关系，最大解析深度 %d", len(opts.Calls), opts.FollowDepth)
+    }
     
     // 使用并行处理加速搜索
     var wg sync.WaitGroup
     concurrency := 4 // 并发数
     dataChan := make(chan *ButtonData)
     
-    writeLog("启动 %d 个并发工作协程", concurrency)
+    logger.Infof("启动 %d 个并发工作协程", concurrency)
     
     // 启动工作协程
     for i := 0; i < concurrency; i++ {
@@ -151,14 +489,7 @@ func main() {
         go func(id int) {
             defer wg.Done()
             for data := range dataChan {
-                buttonStartTime := time.Now()
-                writeLog("[工作协程 %d] 开始搜索按钮: %s", id, data.Button)
-                
-                searchButtonValueInAllFiles(data, allFiles, functionCommentMap, writeLog)
-                
-                data.SearchTime = time.Since(buttonStartTime)
-                writeLog("[工作协程 %d] 完成搜索按钮: %s, 耗时: %v, 找到: %v, 按钮名称: %s", 
-                    id, data.Button, data.SearchTime, data.ButtonValue != "", data.ButtonName)
+                processButton(id, data, searchFiles, functionCommentMap, opts, logger)
             }
         }(i)
     }
@@ -173,15 +504,14 @@ func main() {
     
     // 等待所有搜索完成
     wg.Wait()
-    writeLog("所有按钮搜索完成")
+    logger.Infof("所有按钮搜索完成")
     
     // 统计匹配结果
     var matchedCount, highQualityCount, withNameCount int
     for _, data := range buttonDataList {
         if data.ButtonValue != "" {
             matchedCount++
-            if strings.Contains(data.ButtonValue, "addOpeartionsClickLog") || 
-               strings.Contains(data.ButtonValue, "addOperationsClickLog") {
+            if opts.isClickLogCall(data.ButtonValue) {
                 highQualityCount++
             }
             if data.ButtonName != "" {
@@ -190,7 +520,7 @@ func main() {
         }
     }
     
-    writeLog("匹配结果统计: 总计 %d 个按钮, 成功匹配 %d 个 (%.2f%%), 高质量匹配 %d 个 (%.2f%%), 有名称说明 %d 个 (%.2f%%)",
+    logger.Infof("匹配结果统计: 总计 %d 个按钮, 成功匹配 %d 个 (%.2f%%), 高质量匹配 %d 个 (%.2f%%), 有名称说明 %d 个 (%.2f%%)",
         len(buttonDataList), matchedCount, 
         float64(matchedCount)*100/float64(len(buttonDataList)),
         highQualityCount, 
@@ -198,34 +528,33 @@ func main() {
         withNameCount,
         float64(withNameCount)*100/float64(len(buttonDataList)))
     
-    // 创建输出文件
+    // 写入结果文件，保持TSV格式
     outputFile := "result.txt"
-    outFile, err := os.Create(outputFile)
-    if err != nil {
-        writeLog("创建输出文件失败: %v", err)
+    if err := writeResultFile(outputFile, buttonDataList, columns); err != nil {
+        logger.Errorf("写入输出文件失败: %v", err)
         return
     }
-    defer outFile.Close()
     
-    // 写入表头
-    outFile.WriteString("button\tprojectcode\tpage\t按钮值\t页面上按钮的名称\t页面名称\t源文件\t搜索耗时(ms)\n")
+    // 写入按页面汇总的报告
+    if *byPageFile != "" {
+        if err := writePageRollup(*byPageFile, rollupByPage(buttonDataList)); err != nil {
+            logger.Errorf("写入页面汇总失败: %v", err)
+        } else {
+            logger.Infof("页面汇总已保存到 %s", *byPageFile)
+        }
+    }
     
-    // 写入数据，保持TSV格式
-    for _, data := range buttonDataList {
-        line := fmt.Sprintf("%s\t%s\t%s\t%s\t%s\t%s\t%s\t%d\n",
-            data.Button,
-            data.ProjectCode,
-            data.Page,
-            data.ButtonValue, // 这里可能是空字符串
-            data.ButtonName,  // 这里是从注释或函数名中提取的按钮名称
-            data.PageName,
-            filepath.Base(data.SourceFile),
-            data.SearchTime.Milliseconds())
-        outFile.WriteString(line)
+    // 写入文件匹配覆盖率
+    if opts.Coverage != nil {
+        if err := opts.Coverage.WriteCSV(*coverageFile); err != nil {
+            logger.Errorf("写入覆盖率文件失败: %v", err)
+        } else {
+            logger.Infof("文件匹配覆盖率已保存到 %s", *coverageFile)
+        }
     }
     
     totalTime := time.Since(startTime)
-    writeLog("程序执行完成，总耗时: %v, 结果保存到 %s", totalTime, outputFile)
+    logger.Infof("程序执行完成，总耗时: %v, 结果保存到 %s", totalTime, outputFile)
     
     // 输出汇总结果
     fmt.Printf("\n======== 执行汇总 ========\n")
@@ -241,8 +570,134 @@ func main() {
     fmt.Printf("日志文件: button_search.log\n")
 }
 
+// 搜索单个按钮并记录耗时和汇总日志
+func processButton(workerID int, data *ButtonData, allFiles []string, functionCommentMap map[string]string, opts SearchOptions, logger *Logger) {
+    buttonStartTime := time.Now()
+    logger.Debugf("[工作协程 %d] 开始搜索按钮: %s", workerID, data.Button)
+    
+    searchButtonValueInAllFiles(data, allFiles, functionCommentMap, opts, logger)
+    
+    data.SearchTime = time.Since(buttonStartTime)
+    logger.Infof("[工作协程 %d] 完成搜索按钮: %s, 耗时: %v, 找到: %v, 按钮名称: %s", 
+        workerID, data.Button, data.SearchTime, data.ButtonValue != "", data.ButtonName)
+}
+
+// 解析逗号分隔的输出列，校验列名并保持给定顺序
+func parseColumns(spec string) ([]OutputColumn, error) {
+    byName := make(map[string]OutputColumn, len(outputColumns))
+    names := make([]string, 0, len(outputColumns))
+    for _, column := range outputColumns {
+        byName[column.Name] = column
+        names = append(names, column.Name)
+    }
+    
+    var columns []OutputColumn
+    for _, name := range strings.Split(spec, ",") {
+        name = strings.TrimSpace(name)
+        if name == "" {
+            continue
+        }
+        column, ok := byName[name]
+        if !ok {
+            return nil, fmt.Errorf("不支持的输出列: %s (可用: %s)", name, strings.Join(names, ", "))
+        }
+        columns = append(columns, column)
+    }
+    if len(columns) == 0 {
+        return nil, fmt.Errorf("至少需要指定一个输出列")
+    }
+    
+    return columns, nil
+}
+
+// 将按钮结果按指定的列写入TSV文件
+func writeResultFile(path string, buttonDataList []ButtonData, columns []OutputColumn) error {
+    file, err := os.Create(path)
+    if err != nil {
+        return err
+    }
+    defer file.Close()
+    
+    writer := bufio.NewWriter(file)
+    values := make([]string, len(columns))
+    
+    // 写入表头
+    for i, column := range columns {
+        values[i] = column.Name
+    }
+    writer.WriteString(strings.Join(values, "\t") + "\n")
+    
+    // 写入数据，按钮值可能是空字符串
+    for i := range buttonDataList {
+        for j, column := range columns {
+            values[j] = column.Value(&buttonDataList[i])
+        }
+        writer.WriteString(strings.Join(values, "\t") + "\n")
+    }
+    
+    return writer.Flush()
+}
+
+// 按页面汇总按钮结果，同一页面的源文件和处理函数只保留一次
+func rollupByPage(buttonDataList []ButtonData) []PageRollup {
+    var rollups []PageRollup
+    index := make(map[string]int) // 页面在 rollups 中的位置
+    
+    for _, data := range buttonDataList {
+        i, exists := index[data.Page]
+        if !exists {
+            i = len(rollups)
+            index[data.Page] = i
+            rollups = append(rollups, PageRollup{Page: data.Page, PageName: data.PageName})
+        }
+        
+        rollup := &rollups[i]
+        rollup.Buttons++
+        if data.ButtonValue == "" {
+            continue
+        }
+        if data.SourceFile != "" && !contains(rollup.SourceFiles, data.SourceFile) {
+            rollup.SourceFiles = append(rollup.SourceFiles, data.SourceFile)
+        }
+        if data.ButtonName != "" && !contains(rollup.Handlers, data.ButtonName) {
+            rollup.Handlers = append(rollup.Handlers, data.ButtonName)
+        }
+    }
+    
+    return rollups
+}
+
+// 将页面汇总写入TSV文件
+func writePageRollup(path string, rollups []PageRollup) error {
+    file, err := os.Create(path)
+    if err != nil {
+        return err
+    }
+    defer file.Close()
+    
+    file.WriteString("page\t页面名称\t按钮数\t源文件\t处理函数\n")
+    for _, rollup := range rollups {
+        sourceFiles := make([]string, len(rollup.SourceFiles))
+        for i, sourceFile := range rollup.SourceFiles {
+            sourceFiles[i] = filepath.Base(sourceFile)
+        }
+        
+        line := fmt.Sprintf("%s\t%s\t%d\t%s\t%s\n",
+            rollup.Page,
+            rollup.PageName,
+            rollup.Buttons,
+            strings.Join(sourceFiles, "; "),
+            strings.Join(rollup.Handlers, "; "))
+        if _, err := file.WriteString(line); err != nil {
+            return err
+        }
+    }
+    
+    return nil
+}
+
 // 预先提取所有函数及其注释
-func extractFunctionComments(files []string, logFunc func(string, ...interface{})) map[string]string {
+func extractFunctionComments(files []string, opts SearchOptions, logger *Logger) map[string]string {
     functionCommentMap := make(map[string]string)
     
     for _, filePath := range files {
@@ -251,9 +706,9 @@ func extractFunctionComments(files []string, logFunc func(string, ...interface{}
             continue
         }
         
-        file, err := os.Open(filePath)
+        file, err := readSource(filePath, opts)
         if err != nil {
-            logFunc("打开文件失败: %s, 错误: %v", filePath, err)
+            logger.Errorf("打开文件失败: %s, 错误: %v", filePath, err)
             continue
         }
         
@@ -279,20 +734,180 @@ func extractFunctionComments(files []string, logFunc func(string, ...interface{}
                 // 存储函数名和注释的映射
                 if lastComment != "" {
                     functionCommentMap[funcName] = lastComment
-                    logFunc("提取函数 %s 的注释: %s", funcName, lastComment)
+                    logger.Debugf("提取函数 %s 的注释: %s", funcName, lastComment)
                 }
                 
                 // 重置注释，避免被下一个函数继承
                 lastComment = ""
             }
         }
-        
-        file.Close()
     }
     
     return functionCommentMap
 }
 
+// 函数调用模式
+var callRegex = regexp.MustCompile(`(\w+)\s*\(`)
+
+// 不是函数调用的关键字
+var callKeywords = map[string]bool{
+    "if": true, "for": true, "while": true, "switch": true, "catch": true,
+    "function": true, "return": true, "typeof": true, "new": true,
+}
+
+// 预先提取每个函数体中调用的函数，用于沿调用链解析按钮名称
+func extractFunctionCalls(files []string, opts SearchOptions, logger *Logger) map[string][]string {
+    calls := make(map[string][]string)
+    
+    for _, filePath := range files {
+        // 跳过非JS文件
+        if !strings.HasSuffix(strings.ToLower(filePath), ".js") {
+            continue
+        }
+        
+        file, err := readSource(filePath, opts)
+        if err != nil {
+            logger.Errorf("打开文件失败: %s, 错误: %v", filePath, err)
+            continue
+        }
+        
+        scanner := bufio.NewScanner(file)
+        var currentFunction string
+        
+        for scanner.Scan() {
+            line := strings.TrimSpace(scanner.Text())
+            
+            // 函数定义开始
+            if funcMatch := functionDefRegex.FindStringSubmatch(line); len(funcMatch) > 1 {
+                currentFunction = funcMatch[1]
+                continue
+            }
+            
+            // 与按钮搜索相同，单独一行的右括号视为函数结束
+            if line == "}" {
+                currentFunction = ""
+                continue
+            }
+            
+            if currentFunction == "" || commentRegex.MatchString(line) {
+                continue
+            }
+            
+            for _, callMatch := range callRegex.FindAllStringSubmatch(line, -1) {
+                callee := callMatch[1]
+                if callee == currentFunction || callKeywords[callee] || contains(calls[currentFunction], callee) {
+                    continue
+                }
+                calls[currentFunction] = append(calls[currentFunction], callee)
+            }
+        }
+    }
+    
+    return calls
+}
+
+// 从处理函数出发按调用顺序广度优先查找最近的有注释的函数，最多查找 maxDepth 层，
+// 返回注释和对应的函数名
+func resolveHandlerComment(handler string, calls map[string][]string, functionCommentMap map[string]string, maxDepth int) (string, string) {
+    visited := map[string]bool{handler: true}
+    level := []string{handler}
+    
+    for depth := 0; depth < maxDepth && len(level) > 0; depth++ {
+        var next []string
+        for _, function := range level {
+            for _, callee := range calls[function] {
+                if visited[callee] {
+                    continue
+                }
+                visited[callee] = true
+                if comment, exists := functionCommentMap[callee]; exists {
+                    return comment, callee
+                }
+                next = append(next, callee)
+            }
+        }
+        level = next
+    }
+    
+    return "", ""
+}
+
+// 读取源文件并按指定编码转换为UTF-8文本
+// 按UTF-8读取时替换字符过多则改用GBK重新解码，设置了 opts.Sources 时每个文件只读取一次
+func readSource(filePath string, opts SearchOptions) (io.Reader, error) {
+    if decoded, ok := opts.Sources.Get(filePath); ok {
+        return bytes.NewReader(decoded), nil
+    }
+    
+    content, err := os.ReadFile(filePath)
+    if err != nil {
+        return nil, err
+    }
+    
+    decoded, err := decodeSource(content, opts.SourceEncoding)
+    if err != nil {
+        return nil, err
+    }
+    
+    if isUTF8Encoding(opts.SourceEncoding) && replacementRatio(decoded) > replacementRatioThreshold {
+        fallback, err := decodeSource(content, "gbk")
+        ok := err == nil && replacementRatio(fallback) <= replacementRatioThreshold
+        opts.Fallbacks.Record(filePath, "GBK", ok)
+        if ok {
+            decoded = fallback
+        }
+    }
+    opts.Sources.Put(filePath, decoded)
+    return bytes.NewReader(decoded), nil
+}
+
+// 判断编码名称是否表示UTF-8
+func isUTF8Encoding(encoding string) bool {
+    switch strings.ToLower(encoding) {
+    case "", "utf-8", "utf8":
+        return true
+    }
+    return false
+}
+
+// 计算替换字符(包括无效的UTF-8序列)占非ASCII字符的比例
+func replacementRatio(content []byte) float64 {
+    nonASCII, replaced := 0, 0
+    for len(content) > 0 {
+        r, size := utf8.DecodeRune(content)
+        content = content[size:]
+        if r < utf8.RuneSelf {
+            continue
+        }
+        nonASCII++
+        if r == utf8.RuneError {
+            replaced++
+        }
+    }
+    if nonASCII == 0 {
+        return 0
+    }
+    return float64(replaced) / float64(nonASCII)
+}
+
+// 将源文件内容从指定编码转换为UTF-8，带UTF-8 BOM的文件始终按UTF-8处理
+func decodeSource(content []byte, encoding string) ([]byte, error) {
+    if bytes.HasPrefix(content, []byte("\xef\xbb\xbf")) {
+        return content[3:], nil
+    }
+    
+    if isUTF8Encoding(encoding) {
+        return content, nil
+    }
+    switch strings.ToLower(encoding) {
+    case "gbk":
+        return simplifiedchinese.GBK.NewDecoder().Bytes(content)
+    case "gb18030":
+        return simplifiedchinese.GB18030.NewDecoder().Bytes(content)
+    }
+    return nil, fmt.Errorf("不支持的源文件编码: %s", encoding)
+}
+
 // 解析TSV文件
 func parseTsvFile(file io.Reader) ([]ButtonData, error) {
     scanner := bufio.NewScanner(file)
@@ -385,8 +1000,83 @@ func collectAllFiles(rootDir string) ([]string, error) {
     return files, err
 }
 
+// 返回需要搜索的文件，指定了提交范围时只保留范围内变更的文件，获取变更失败时搜索全部文件
+func filesToSearch(projectDir string, gitRange string, allFiles []string, logger *Logger) []string {
+    if gitRange == "" {
+        return allFiles
+    }
+    
+    changedFiles, err := gitChangedFiles(projectDir, gitRange)
+    if err != nil {
+        logger.Infof("获取git变更文件失败，回退到全量搜索: %v", err)
+        return allFiles
+    }
+    
+    files := filterChangedFiles(allFiles, changedFiles)
+    logger.Infof("提交范围 %s 内共有 %d 个变更的HTML/JS文件", gitRange, len(files))
+    return files
+}
+
+// 获取提交范围内变更的文件列表（绝对路径）
+func gitChangedFiles(dir string, gitRange string) ([]string, error) {
+    // 以 - 开头的值会被 git 当作选项解析
+    if strings.HasPrefix(gitRange, "-") {
+        return nil, fmt.Errorf("无效的提交范围: %s", gitRange)
+    }
+    
+    // 获取仓库根目录，git diff 输出的路径相对于根目录
+    out, err := exec.Command("git", "-C", dir, "rev-parse", "--show-toplevel").Output()
+    if err != nil {
+        return nil, fmt.Errorf("不是git仓库: %v", err)
+    }
+    topLevel := strings.TrimSpace(string(out))
+    
+    out, err = exec.Command("git", "-C", dir, "diff", "--name-only", gitRange).Output()
+    if err != nil {
+        return nil, fmt.Errorf("执行git diff失败: %v", err)
+    }
+    
+    var files []string
+    for _, line := range strings.Split(string(out), "\n") {
+        line = strings.TrimSpace(line)
+        if line != "" {
+            files = append(files, filepath.Join(topLevel, filepath.FromSlash(line)))
+        }
+    }
+    
+    return files, nil
+}
+
+// 从所有文件中筛选出变更过的文件
+func filterChangedFiles(allFiles []string, changedFiles []string) []string {
+    changed := make(map[string]bool, len(changedFiles))
+    for _, file := range changedFiles {
+        changed[absPath(file)] = true
+    }
+    
+    var files []string
+    for _, file := range allFiles {
+        if changed[absPath(file)] {
+            files = append(files, file)
+        }
+    }
+    
+    return files
+}
+
+// 获取规范化的绝对路径，失败时返回清理后的原路径
+func absPath(path string) string {
+    if abs, err := filepath.Abs(path); err == nil {
+        if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+            return resolved
+        }
+        return abs
+    }
+    return filepath.Clean(path)
+}
+
 // 在所有文件中查找按钮内容
-func searchButtonValueInAllFiles(data *ButtonData, allFiles []string, functionCommentMap map[string]string, logFunc func(string, ...interface{})) {
+func searchButtonValueInAllFiles(data *ButtonData, allFiles []string, functionCommentMap map[string]string, opts SearchOptions, logger *Logger) {
     if data.Button == "" {
         return
     }
@@ -405,28 +1095,25 @@ func searchButtonValueInAllFiles(data *ButtonData, allFiles []string, functionCo
     if dynamicSuffix != "" && dynamicFuncName != "" {
         if comment, exists := functionCommentMap[dynamicFuncName]; exists {
             data.ButtonName = comment
-            logFunc("按钮 '%s': 从函数定义中找到名称: %s", data.Button, comment)
+            logger.Debugf("按钮 '%s': 从函数定义中找到名称: %s", data.Button, comment)
         }
     }
     
-    // 优先尝试先搜索与页面名称相关的文件
-    pageFile := filepath.Base(data.Page)
-    fileBase := strings.TrimSuffix(pageFile, filepath.Ext(pageFile))
-    
-    logFunc("按钮 '%s': 开始搜索, 相关页面: %s", data.Button, data.Page)
+    logger.Debugf("按钮 '%s': 开始搜索, 相关页面: %s", data.Button, data.Page)
     
     // 先搜索可能性更高的文件（基于页面名称）
-    relevantFiles := filterRelevantFiles(allFiles, fileBase)
-    logFunc("按钮 '%s': 找到 %d 个相关文件", data.Button, len(relevantFiles))
+    relevantFiles := relevantFilesForPage(allFiles, data.Page)
+    logger.Debugf("按钮 '%s': 找到 %d 个相关文件", data.Button, len(relevantFiles))
     
     // 存储最佳匹配结果
     var bestMatch MatchResult
     
     // 首先在可能性高的文件中查找
     for _, filePath := range relevantFiles {
-        match, err := searchButtonInFile(filePath, data.Button, dynamicSuffix, functionCommentMap)
+        match, err := searchButtonInFile(filePath, data.Button, dynamicSuffix, functionCommentMap, opts)
+        opts.Coverage.Record(filePath, err == nil && match.Line != "")
         if err == nil && match.Line != "" {
-            logFunc("按钮 '%s': 在文件 %s 中找到匹配, 质量级别: %d", 
+            logger.Debugf("按钮 '%s': 在文件 %s 中找到匹配, 质量级别: %d", 
                 data.Button, filepath.Base(filePath), match.Quality)
             
             // 更新最佳匹配
@@ -443,7 +1130,7 @@ func searchButtonValueInAllFiles(data *ButtonData, allFiles []string, functionCo
     
     // 如果在相关文件中未找到高质量匹配，则地毯式搜索所有文件
     if bestMatch.Quality < MatchQualityHigh {
-        logFunc("按钮 '%s': 在相关文件中未找到高质量匹配，开始全局搜索", data.Button)
+        logger.Debugf("按钮 '%s': 在相关文件中未找到高质量匹配，开始全局搜索", data.Button)
         
         for _, filePath := range allFiles {
             // 跳过已经搜索过的文件
@@ -451,9 +1138,10 @@ func searchButtonValueInAllFiles(data *ButtonData, allFiles []string, functionCo
                 continue
             }
             
-            match, err := searchButtonInFile(filePath, data.Button, dynamicSuffix, functionCommentMap)
+            match, err := searchButtonInFile(filePath, data.Button, dynamicSuffix, functionCommentMap, opts)
+            opts.Coverage.Record(filePath, err == nil && match.Line != "")
             if err == nil && match.Line != "" {
-                logFunc("按钮 '%s': 在文件 %s 中找到匹配, 质量级别: %d", 
+                logger.Debugf("按钮 '%s': 在文件 %s 中找到匹配, 质量级别: %d", 
                     data.Button, filepath.Base(filePath), match.Quality)
                 
                 // 更新最佳匹配
@@ -473,20 +1161,57 @@ func searchButtonValueInAllFiles(data *ButtonData, allFiles []string, functionCo
     if bestMatch.Line != "" {
         data.ButtonValue = bestMatch.Line
         data.SourceFile = bestMatch.FilePath
+        data.SourceLine = bestMatch.LineNumber
         
         // 优先使用从文件中找到的按钮名称
         if bestMatch.ButtonName != "" {
             data.ButtonName = bestMatch.ButtonName
         }
         
-        logFunc("按钮 '%s': 最终使用匹配结果, 质量级别: %d, 源文件: %s, 按钮名称: %s", 
+        // 处理函数没有注释时，沿调用链查找其他文件中有注释的函数
+        if _, commented := functionCommentMap[bestMatch.Function]; bestMatch.Function != "" && !commented && opts.FollowDepth > 0 {
+            if comment, callee := resolveHandlerComment(bestMatch.Function, opts.Calls, functionCommentMap, opts.FollowDepth); comment != "" {
+                data.ButtonName = comment
+                logger.Debugf("按钮 '%s': 处理函数 %s 调用的 %s 有注释: %s", data.Button, bestMatch.Function, callee, comment)
+            }
+        }
+        
+        logger.Debugf("按钮 '%s': 最终使用匹配结果, 质量级别: %d, 源文件: %s, 按钮名称: %s", 
             data.Button, bestMatch.Quality, filepath.Base(bestMatch.FilePath), data.ButtonName)
     } else {
         data.ButtonValue = ""
-        logFunc("按钮 '%s': 未找到任何匹配", data.Button)
+        data.SourceLine = 0
+        logger.Debugf("按钮 '%s': 未找到任何匹配", data.Button)
     }
 }
 
+// 统计并输出演练结果
+func reportDryRun(buttonDataList []ButtonData, allFiles []string, logger *Logger) DryRunReport {
+    report := DryRunReport{
+        Buttons:       len(buttonDataList),
+        Files:         len(allFiles),
+        RelevantFiles: make([]int, len(buttonDataList)),
+    }
+    
+    totalRelevant := 0
+    for i, data := range buttonDataList {
+        report.RelevantFiles[i] = len(relevantFilesForPage(allFiles, data.Page))
+        totalRelevant += report.RelevantFiles[i]
+        logger.Infof("按钮 '%s' (页面 %s): %d 个相关文件", data.Button, data.Page, report.RelevantFiles[i])
+    }
+    
+    logger.Infof("演练完成: %d 个按钮, %d 个文件, 共 %d 个相关文件, 未执行搜索",
+        report.Buttons, report.Files, totalRelevant)
+    return report
+}
+
+// 与按钮所在页面相关的文件，按页面文件名(不含扩展名)筛选
+func relevantFilesForPage(allFiles []string, page string) []string {
+    pageFile := filepath.Base(page)
+    fileBase := strings.TrimSuffix(pageFile, filepath.Ext(pageFile))
+    return filterRelevantFiles(allFiles, fileBase)
+}
+
 // 筛选与页面名称相关的文件（提高搜索效率）
 func filterRelevantFiles(allFiles []string, baseName string) []string {
     var relevantFiles []string
@@ -503,6 +1228,82 @@ func filterRelevantFiles(allFiles []string, baseName string) []string {
     return relevantFiles
 }
 
+// 解析逗号分隔的点击日志函数名
+func parseLogFuncs(spec string) []string {
+    var funcs []string
+    for _, fn := range strings.Split(spec, ",") {
+        if fn = strings.TrimSpace(fn); fn != "" {
+            funcs = append(funcs, fn)
+        }
+    }
+    return funcs
+}
+
+// 按文件扩展名返回高/中/低优先级匹配模式：
+// HTML 文件侧重 onclick、id、class 等属性，JS 文件侧重 logFuncs 中点击日志函数的调用，
+// 其他文件使用两者合并的模式
+func buttonPatternSets(filePath string, baseButtonPattern string, dynamicButtonPattern string, logFuncs []string, objectArgOnly bool) ([]string, []string, []string) {
+    // 点击日志调用 (更可能是真实的按钮点击处理)
+    var clickLogPatterns []string
+    for _, name := range logFuncs {
+        fn := regexp.QuoteMeta(name)
+        clickLogPatterns = append(clickLogPatterns,
+            fn + `\s*\(\s*\{\s*button\s*:\s*["']` + baseButtonPattern + `["']`,
+            fn + `\s*\(\s*\{\s*button\s*:\s*[^}]*` + baseButtonPattern, // 动态构造的按钮
+        )
+        
+        // 直接以按钮标识为参数，objectArgOnly 时按普通调用匹配
+        if !objectArgOnly {
+            clickLogPatterns = append(clickLogPatterns, fn + `\s*\(\s*["']` + baseButtonPattern + `["']`)
+        }
+        
+        // 如果是动态按钮，添加特定后缀模式
+        if dynamicButtonPattern != "" {
+            clickLogPatterns = append(clickLogPatterns, fn + `\s*\(\s*\{\s*button\s*:\s*[^}]*` + dynamicButtonPattern)
+        }
+    }
+    
+    // 函数调用和按钮定义 (可能是按钮相关，但不一定是点击处理)
+    callPatterns := []string{
+        // 作为事件处理函数中的参数
+        `\(\s*["']` + baseButtonPattern + `["']\s*\)`,
+        // 按钮定义模式
+        `button\s*:\s*["']` + baseButtonPattern + `["']`,
+        `button\s*:\s*[^,}]*` + baseButtonPattern, // 动态构造的按钮
+    }
+    
+    // 作为按钮ID或Class
+    attributePatterns := []string{
+        `id\s*=\s*["']` + baseButtonPattern + `["']`,
+        `class\s*=\s*["'][^"']*` + baseButtonPattern + `[^"']*["']`,
+    }
+    
+    // 低优先级匹配模式 (最宽泛的匹配)
+    lowPriorityPatterns := []string{
+        // 直接匹配
+        baseButtonPattern,
+        // 作为字符串
+        `["']` + baseButtonPattern + `["']`,
+    }
+    
+    switch strings.ToLower(filepath.Ext(filePath)) {
+    case ".html", ".htm":
+        // 事件属性中引用按钮，页面内联脚本中的点击日志同样视为高优先级
+        eventPatterns := []string{
+            `on\w+\s*=\s*"[^"]*` + baseButtonPattern,
+            `on\w+\s*=\s*'[^']*` + baseButtonPattern,
+        }
+        dataPatterns := []string{
+            `data-[\w-]+\s*=\s*["']` + baseButtonPattern + `["']`,
+        }
+        return append(eventPatterns, clickLogPatterns...), append(attributePatterns, dataPatterns...), lowPriorityPatterns
+    case ".js":
+        return clickLogPatterns, callPatterns, lowPriorityPatterns
+    default:
+        return clickLogPatterns, append(callPatterns, attributePatterns...), lowPriorityPatterns
+    }
+}
+
 // 检查slice是否包含字符串
 func contains(slice []string, item string) bool {
     for _, s := range slice {
@@ -514,16 +1315,15 @@ func contains(slice []string, item string) bool {
 }
 
 // 在文件中搜索按钮内容，返回匹配质量与内容
-func searchButtonInFile(filePath string, buttonText string, dynamicSuffix string, functionCommentMap map[string]string) (MatchResult, error) {
+func searchButtonInFile(filePath string, buttonText string, dynamicSuffix string, functionCommentMap map[string]string, opts SearchOptions) (MatchResult, error) {
     // 空结果
     emptyResult := MatchResult{Quality: -1, FilePath: filePath}
     
-    // 打开文件
-    file, err := os.Open(filePath)
+    // 读取文件并转换为UTF-8
+    file, err := readSource(filePath, opts)
     if err != nil {
         return emptyResult, err
     }
-    defer file.Close()
     
     scanner := bufio.NewScanner(file)
     
@@ -547,55 +1347,28 @@ func searchButtonInFile(filePath string, buttonText string, dynamicSuffix string
         baseButtonPattern = regexp.QuoteMeta(buttonText)
     }
     
-    // 高优先级匹配模式 (更可能是真实的按钮点击处理)
-    highPriorityPatterns := []string{
-        // addOpeartionsClickLog 模式
-        `addOpeartionsClickLog\s*\(\s*\{\s*button\s*:\s*["']` + baseButtonPattern + `["']`,
-        `addOpeartionsClickLog\s*\(\s*\{\s*button\s*:\s*[^}]*` + baseButtonPattern, // 动态构造的按钮
-        `addOperationsClickLog\s*\(\s*\{\s*button\s*:\s*["']` + baseButtonPattern + `["']`,
-        `addOperationsClickLog\s*\(\s*\{\s*button\s*:\s*[^}]*` + baseButtonPattern, // 动态构造的按钮
-    }
-    
-    // 如果是动态按钮，添加特定后缀模式
-    if dynamicSuffix != "" {
-        highPriorityPatterns = append(highPriorityPatterns,
-            `addOpeartionsClickLog\s*\(\s*\{\s*button\s*:\s*[^}]*` + dynamicButtonPattern,
-            `addOperationsClickLog\s*\(\s*\{\s*button\s*:\s*[^}]*` + dynamicButtonPattern,
-        )
-    }
-    
-    // 中等优先级匹配模式 (可能是按钮相关，但不一定是点击处理)
-    mediumPriorityPatterns := []string{
-        // 作为事件处理函数中的参数
-        `\(\s*["']` + baseButtonPattern + `["']\s*\)`,
-        // 按钮定义模式
-        `button\s*:\s*["']` + baseButtonPattern + `["']`,
-        `button\s*:\s*[^,}]*` + baseButtonPattern, // 动态构造的按钮
-        // 作为按钮ID或Class
-        `id\s*=\s*["']` + baseButtonPattern + `["']`,
-        `class\s*=\s*["'][^"']*` + baseButtonPattern + `[^"']*["']`,
-    }
+    // 按文件类型选择高/中/低优先级匹配模式
+    highPriorityPatterns, mediumPriorityPatterns, lowPriorityPatterns :=
+        buttonPatternSets(filePath, baseButtonPattern, dynamicButtonPattern, opts.logFuncs(), opts.ObjectArgOnly)
     
-    // 低优先级匹配模式 (最宽泛的匹配)
-    lowPriorityPatterns := []string{
-        // 直接匹配
-        baseButtonPattern,
-        // 作为字符串
-        `["']` + baseButtonPattern + `["']`,
+    // 默认不区分大小写
+    flags := `(?i)`
+    if opts.CaseSensitive {
+        flags = ""
     }
     
     // 组合所有正则表达式
-    highPriorityRegex, err := regexp.Compile(`(?i)(` + strings.Join(highPriorityPatterns, "|") + `)`)
+    highPriorityRegex, err := regexp.Compile(flags + `(` + strings.Join(highPriorityPatterns, "|") + `)`)
     if err != nil {
         return emptyResult, err
     }
     
-    mediumPriorityRegex, err := regexp.Compile(`(?i)(` + strings.Join(mediumPriorityPatterns, "|") + `)`)
+    mediumPriorityRegex, err := regexp.Compile(flags + `(` + strings.Join(mediumPriorityPatterns, "|") + `)`)
     if err != nil {
         return emptyResult, err
     }
     
-    lowPriorityRegex, err := regexp.Compile(`(?i)(` + strings.Join(lowPriorityPatterns, "|") + `)`)
+    lowPriorityRegex, err := regexp.Compile(flags + `(` + strings.Join(lowPriorityPatterns, "|") + `)`)
     if err != nil {
         return emptyResult, err
     }
@@ -664,6 +1437,8 @@ func searchButtonInFile(filePath string, buttonText string, dynamicSuffix string
                 Quality:   MatchQualityHigh,
                 FilePath:  filePath,
                 ButtonName: buttonName,
+                Function:  currentFunction,
+                LineNumber: lineNum,
             }, nil
         } else if mediumPriorityRegex.MatchString(cleanLine) {
             // 中优先级匹配，记录但继续搜索高优先级匹配
@@ -690,6 +1465,8 @@ func searchButtonInFile(filePath string, buttonText string, dynamicSuffix string
                     Quality:   MatchQualityMedium,
                     FilePath:  filePath,
                     ButtonName: buttonName,
+                    Function:  currentFunction,
+                    LineNumber: lineNum,
                 }
             }
         } else if lowPriorityRegex.MatchString(cleanLine) {
@@ -717,6 +1494,8 @@ func searchButtonInFile(filePath string, buttonText string, dynamicSuffix string
                     Quality:   MatchQualityLow,
                     FilePath:  filePath,
                     ButtonName: buttonName,
+                    Function:  currentFunction,
+                    LineNumber: lineNum,
                 }
             }
         }
@@ -0,0 +1,749 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+// 创建测试用的项目文件
+func writeTestFiles(t *testing.T, dir string, files map[string]string) {
+    t.Helper()
+    for name, content := range files {
+        filePath := filepath.Join(dir, name)
+        if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+            t.Fatalf("创建目录失败 %s: %v", name, err)
+        }
+        if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+            t.Fatalf("创建测试文件失败 %s: %v", name, err)
+        }
+    }
+}
+
+// 测试只搜索变更文件列表中的文件
+func TestFilterChangedFiles(t *testing.T) {
+    tempDir := t.TempDir()
+    writeTestFiles(t, tempDir, map[string]string{
+        "a.js":        "addOperationsClickLog({button: 'btn_a'})",
+        "b.js":        "addOperationsClickLog({button: 'btn_b'})",
+        "page/c.html": "<div id='btn_c'></div>",
+    })
+
+    allFiles, err := collectAllFiles(tempDir)
+    if err != nil {
+        t.Fatalf("收集文件失败: %v", err)
+    }
+    if len(allFiles) != 3 {
+        t.Fatalf("应该收集到3个文件，但实际得到 %d 个", len(allFiles))
+    }
+
+    // 模拟 git diff 返回的变更文件列表
+    changed := []string{
+        filepath.Join(tempDir, "b.js"),
+        filepath.Join(tempDir, "page", "c.html"),
+        filepath.Join(tempDir, "deleted.js"), // 已删除的文件不在收集结果中
+    }
+
+    files := filterChangedFiles(allFiles, changed)
+    if len(files) != 2 {
+        t.Fatalf("应该只保留2个变更文件，但实际得到 %v", files)
+    }
+    for _, file := range files {
+        if filepath.Base(file) == "a.js" {
+            t.Errorf("未变更的文件 a.js 不应被搜索")
+        }
+    }
+}
+
+// 测试非git目录时返回错误以便回退到全量搜索
+func TestGitChangedFilesNotRepo(t *testing.T) {
+    tempDir := t.TempDir()
+    if _, err := gitChangedFiles(tempDir, "HEAD~1..HEAD"); err == nil {
+        t.Errorf("非git目录应该返回错误")
+    }
+}
+
+// 测试以 - 开头的提交范围被拒绝，不会作为选项传给 git
+func TestGitChangedFilesRejectsOptions(t *testing.T) {
+    for _, gitRange := range []string{"--output=/tmp/diff.txt", "-p"} {
+        _, err := gitChangedFiles(t.TempDir(), gitRange)
+        if err == nil || !strings.Contains(err.Error(), "无效的提交范围") {
+            t.Errorf("%s 应被拒绝，得到 %v", gitRange, err)
+        }
+    }
+}
+
+// 测试只搜索变更的文件时，未变更文件中的函数注释仍用于按钮名称
+func TestGitRangeKeepsCommentsFromUnchangedFiles(t *testing.T) {
+    if _, err := exec.LookPath("git"); err != nil {
+        t.Skip("没有安装git")
+    }
+    tempDir := t.TempDir()
+    git := func(args ...string) {
+        t.Helper()
+        cmd := exec.Command("git", append([]string{"-C", tempDir, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+        if out, err := cmd.CombinedOutput(); err != nil {
+            t.Fatalf("git %v 失败: %v\n%s", args, err, out)
+        }
+    }
+
+    writeTestFiles(t, tempDir, map[string]string{
+        "a.js":     "function onBuyClick() {\n}\n",
+        "lib/b.js": "// 提交订单\nfunction submitOrder(id) {\n    ajaxPost(id);\n}\n",
+    })
+    git("init", "-q")
+    git("add", "-A")
+    git("commit", "-q", "-m", "init")
+    writeTestFiles(t, tempDir, map[string]string{
+        "a.js": "function onBuyClick() {\n    addOperationsClickLog({button: 'btn_buy'});\n    submitOrder(1);\n}\n",
+    })
+    git("commit", "-q", "-am", "change a.js")
+
+    allFiles, err := collectAllFiles(tempDir)
+    if err != nil {
+        t.Fatalf("收集文件失败: %v", err)
+    }
+    logger := NewLogger(LogLevelError)
+    searchFiles := filesToSearch(tempDir, "HEAD~1..HEAD", allFiles, logger)
+    if len(searchFiles) != 1 || filepath.Base(searchFiles[0]) != "a.js" {
+        t.Fatalf("应只搜索变更的 a.js，得到 %v", searchFiles)
+    }
+
+    opts := SearchOptions{FollowDepth: 1}
+    functionCommentMap := extractFunctionComments(allFiles, opts, logger)
+    opts.Calls = extractFunctionCalls(allFiles, opts, logger)
+    data := &ButtonData{Button: "btn_buy", Page: "a.html"}
+    searchButtonValueInAllFiles(data, searchFiles, functionCommentMap, opts, logger)
+    if data.ButtonName != "提交订单" {
+        t.Errorf("应使用未变更的 b.js 中的注释，得到 %q", data.ButtonName)
+    }
+}
+
+// 测试不同日志级别的输出详细程度
+func TestLogLevels(t *testing.T) {
+    tempDir := t.TempDir()
+    writeTestFiles(t, tempDir, map[string]string{
+        "index.js":  "addOperationsClickLog({button: 'btn_buy'})",
+        "other.js":  "var x = 'btn_buy';",
+        "broken.js": "// no match here",
+    })
+
+    allFiles, err := collectAllFiles(tempDir)
+    if err != nil {
+        t.Fatalf("收集文件失败: %v", err)
+    }
+
+    testCases := []struct {
+        level       string
+        expectInfo  bool
+        expectDebug bool
+    }{
+        {"error", false, false},
+        {"info", true, false},
+        {"debug", true, true},
+    }
+
+    for _, tc := range testCases {
+        t.Run(tc.level, func(t *testing.T) {
+            level, err := parseLogLevel(tc.level)
+            if err != nil {
+                t.Fatalf("解析日志级别失败: %v", err)
+            }
+
+            var buf bytes.Buffer
+            logger := NewLogger(level, &buf)
+            data := &ButtonData{Button: "btn_buy", Page: "index.html"}
+            processButton(1, data, allFiles, map[string]string{}, SearchOptions{}, logger)
+            output := buf.String()
+
+            hasSummary := strings.Contains(output, "完成搜索按钮: btn_buy")
+            hasFileMatch := strings.Contains(output, "中找到匹配")
+            if hasSummary != tc.expectInfo {
+                t.Errorf("级别 %s: 按钮汇总日志出现=%v，期望 %v\n%s", tc.level, hasSummary, tc.expectInfo, output)
+            }
+            if hasFileMatch != tc.expectDebug {
+                t.Errorf("级别 %s: 文件匹配日志出现=%v，期望 %v\n%s", tc.level, hasFileMatch, tc.expectDebug, output)
+            }
+        })
+    }
+
+    if _, err := parseLogLevel("verbose"); err == nil {
+        t.Errorf("无效的日志级别应该返回错误")
+    }
+}
+
+// 测试区分大小写模式下仅大小写不同的标识不再匹配
+func TestCaseSensitiveMatching(t *testing.T) {
+    tempDir := t.TempDir()
+    writeTestFiles(t, tempDir, map[string]string{
+        "page.js": "addOperationsClickLog({button: 'BTN_Buy'})",
+    })
+    filePath := filepath.Join(tempDir, "page.js")
+
+    // 默认不区分大小写，应该匹配
+    match, err := searchButtonInFile(filePath, "btn_buy", "", map[string]string{}, SearchOptions{})
+    if err != nil {
+        t.Fatalf("搜索文件失败: %v", err)
+    }
+    if match.Line == "" {
+        t.Errorf("默认模式下应该匹配仅大小写不同的按钮标识")
+    }
+
+    // 区分大小写时不应匹配
+    match, err = searchButtonInFile(filePath, "btn_buy", "", map[string]string{}, SearchOptions{CaseSensitive: true})
+    if err != nil {
+        t.Fatalf("搜索文件失败: %v", err)
+    }
+    if match.Line != "" {
+        t.Errorf("区分大小写模式下不应匹配，得到 %q", match.Line)
+    }
+
+    // 大小写完全一致时仍然匹配
+    match, err = searchButtonInFile(filePath, "BTN_Buy", "", map[string]string{}, SearchOptions{CaseSensitive: true})
+    if err != nil {
+        t.Fatalf("搜索文件失败: %v", err)
+    }
+    if match.Line == "" {
+        t.Errorf("区分大小写模式下大小写一致的标识应该匹配")
+    }
+}
+
+// 测试覆盖率报告中有匹配和无匹配的文件次数正确
+func TestCoverageReport(t *testing.T) {
+    tempDir := t.TempDir()
+    writeTestFiles(t, tempDir, map[string]string{
+        "shop.js":  "addOperationsClickLog({button: 'btn_buy'})\naddOperationsClickLog({button: 'btn_share'})",
+        "share.js": "var name = 'btn_share';",
+        "dead.js":  "function unused() {}",
+    })
+
+    allFiles, err := collectAllFiles(tempDir)
+    if err != nil {
+        t.Fatalf("收集文件失败: %v", err)
+    }
+
+    opts := SearchOptions{Coverage: NewCoverage()}
+    logger := NewLogger(LogLevelError)
+    for _, button := range []string{"btn_buy", "btn_share"} {
+        data := &ButtonData{Button: button, Page: "none.html"}
+        processButton(1, data, allFiles, map[string]string{}, opts, logger)
+    }
+
+    coverageFile := filepath.Join(tempDir, "coverage.csv")
+    if err := opts.Coverage.WriteCSV(coverageFile); err != nil {
+        t.Fatalf("写入覆盖率文件失败: %v", err)
+    }
+
+    file, err := os.Open(coverageFile)
+    if err != nil {
+        t.Fatalf("打开覆盖率文件失败: %v", err)
+    }
+    defer file.Close()
+    records, err := csv.NewReader(file).ReadAll()
+    if err != nil {
+        t.Fatalf("解析覆盖率文件失败: %v", err)
+    }
+
+    if len(records) != 4 || strings.Join(records[0], ",") != "file,matches" {
+        t.Fatalf("覆盖率文件应包含表头和3个文件，得到 %v", records)
+    }
+    counts := make(map[string]string)
+    for _, record := range records[1:] {
+        counts[filepath.Base(record[0])] = record[1]
+    }
+
+    expected := map[string]string{"shop.js": "2", "share.js": "1", "dead.js": "0"}
+    for name, count := range expected {
+        if counts[name] != count {
+            t.Errorf("文件 %s 的匹配次数应为 %s，得到 %q", name, count, counts[name])
+        }
+    }
+}
+
+// 测试GBK编码的源文件中文注释能正确提取为按钮名称
+func TestGBKSourceEncoding(t *testing.T) {
+    tempDir := t.TempDir()
+    source := "// 立即购买按钮\n" +
+        "function buyNow() {\n" +
+        "    addOperationsClickLog({button: 'btn_buy'})\n" +
+        "}\n"
+    encoded, err := simplifiedchinese.GBK.NewEncoder().String(source)
+    if err != nil {
+        t.Fatalf("GBK编码失败: %v", err)
+    }
+    filePath := filepath.Join(tempDir, "shop.js")
+    if err := os.WriteFile(filePath, []byte(encoded), 0644); err != nil {
+        t.Fatalf("创建测试文件失败: %v", err)
+    }
+
+    opts := SearchOptions{SourceEncoding: "gbk"}
+    logger := NewLogger(LogLevelError)
+    comments := extractFunctionComments([]string{filePath}, opts, logger)
+    if comments["buyNow"] != "立即购买按钮" {
+        t.Errorf("函数注释应为 立即购买按钮，得到 %q", comments["buyNow"])
+    }
+
+    data := &ButtonData{Button: "btn_buy", Page: "shop.html"}
+    processButton(1, data, []string{filePath}, comments, opts, logger)
+    if data.ButtonName != "立即购买按钮" {
+        t.Errorf("按钮名称应为 立即购买按钮，得到 %q", data.ButtonName)
+    }
+
+    // 按UTF-8读取时替换字符过多，自动改用GBK
+    utf8Comments := extractFunctionComments([]string{filePath}, SearchOptions{}, logger)
+    if utf8Comments["buyNow"] != "立即购买按钮" {
+        t.Errorf("未指定GBK编码时应回退为GBK解码注释，得到 %q", utf8Comments["buyNow"])
+    }
+}
+
+// 测试按UTF-8读取GBK文件时回退为GBK后才能匹配中文按钮文案，并记录使用的编码
+func TestGBKFallback(t *testing.T) {
+    tempDir := t.TempDir()
+    source := "<div>\n" +
+        "    <a href=\"javascript:void(0)\" onclick=\"share()\">分享给好友</a>\n" +
+        "</div>\n"
+    encoded, err := simplifiedchinese.GBK.NewEncoder().String(source)
+    if err != nil {
+        t.Fatalf("GBK编码失败: %v", err)
+    }
+    filePath := filepath.Join(tempDir, "share.html")
+    if err := os.WriteFile(filePath, []byte(encoded), 0644); err != nil {
+        t.Fatalf("创建测试文件失败: %v", err)
+    }
+
+    var logs bytes.Buffer
+    opts := SearchOptions{SourceEncoding: "utf-8", Fallbacks: NewEncodingFallbacks(NewLogger(LogLevelInfo, &logs))}
+    match, err := searchButtonInFile(filePath, "分享给好友", "", nil, opts)
+    if err != nil {
+        t.Fatalf("搜索失败: %v", err)
+    }
+    if !strings.Contains(match.Line, "分享给好友") {
+        t.Errorf("回退为GBK后应匹配到按钮文案，得到 %q", match.Line)
+    }
+    if !strings.Contains(logs.String(), "已改用 GBK 读取") {
+        t.Errorf("日志应记录改用的编码，得到 %q", logs.String())
+    }
+
+    // 同一文件只记录一次
+    searchButtonInFile(filePath, "分享给好友", "", nil, opts)
+    if n := strings.Count(logs.String(), filePath); n != 1 {
+        t.Errorf("同一文件应只记录一次回退，得到 %d 次", n)
+    }
+
+    // 少量无效字节不触发回退
+    if ratio := replacementRatio([]byte("按钮\xff文案")); ratio > replacementRatioThreshold {
+        t.Errorf("少量无效字节的替换比例应低于阈值，得到 %.2f", ratio)
+    }
+}
+
+// 测试源文件缓存：同一文件只读取和解码一次，回退编码也只记录一次
+func TestReadSourceCache(t *testing.T) {
+    tempDir := t.TempDir()
+    encoded, err := simplifiedchinese.GBK.NewEncoder().String("<button>分享给好友</button>")
+    if err != nil {
+        t.Fatalf("GBK编码失败: %v", err)
+    }
+    filePath := filepath.Join(tempDir, "share.html")
+    if err := os.WriteFile(filePath, []byte(encoded), 0644); err != nil {
+        t.Fatalf("创建测试文件失败: %v", err)
+    }
+
+    var logs bytes.Buffer
+    opts := SearchOptions{
+        SourceEncoding: "utf-8",
+        Fallbacks:      NewEncodingFallbacks(NewLogger(LogLevelInfo, &logs)),
+        Sources:        NewSourceCache(),
+    }
+    read := func(opts SearchOptions) string {
+        r, err := readSource(filePath, opts)
+        if err != nil {
+            t.Fatalf("读取源文件失败: %v", err)
+        }
+        content, _ := io.ReadAll(r)
+        return string(content)
+    }
+    if content := read(opts); !strings.Contains(content, "分享给好友") {
+        t.Fatalf("应按GBK解码，得到 %q", content)
+    }
+
+    // 修改文件后仍返回缓存的解码结果，说明没有重新读取
+    if err := os.WriteFile(filePath, []byte("<button>changed</button>"), 0644); err != nil {
+        t.Fatalf("修改测试文件失败: %v", err)
+    }
+    if content := read(opts); !strings.Contains(content, "分享给好友") {
+        t.Errorf("第二次读取应使用缓存，得到 %q", content)
+    }
+    if n := strings.Count(logs.String(), filePath); n != 1 {
+        t.Errorf("回退编码应只记录一次，得到 %d 次", n)
+    }
+
+    // 不使用缓存时每次重新读取
+    opts.Sources = nil
+    if content := read(opts); !strings.Contains(content, "changed") {
+        t.Errorf("没有缓存时应重新读取文件，得到 %q", content)
+    }
+}
+
+// 测试按页面汇总时同一页面的处理函数去重
+func TestRollupByPage(t *testing.T) {
+    buttonDataList := []ButtonData{
+        {Button: "btn_a", Page: "shop.html", PageName: "商城", ButtonValue: "goDetailPage()", ButtonName: "进入详情", SourceFile: "/src/shop.js"},
+        {Button: "btn_b", Page: "shop.html", PageName: "商城", ButtonValue: "goDetailPage()", ButtonName: "进入详情", SourceFile: "/src/shop.js"},
+        {Button: "btn_c", Page: "shop.html", PageName: "商城", ButtonValue: "share()", ButtonName: "分享", SourceFile: "/src/common.js"},
+        {Button: "btn_d", Page: "shop.html", PageName: "商城"}, // 未匹配
+        {Button: "btn_e", Page: "home.html", PageName: "首页", ButtonValue: "share()", ButtonName: "分享", SourceFile: "/src/common.js"},
+    }
+
+    rollups := rollupByPage(buttonDataList)
+    if len(rollups) != 2 {
+        t.Fatalf("应该汇总为2个页面，得到 %d 个", len(rollups))
+    }
+
+    shop := rollups[0]
+    if shop.Page != "shop.html" || shop.Buttons != 4 {
+        t.Errorf("shop.html 应有4个按钮，得到 %+v", shop)
+    }
+    if strings.Join(shop.Handlers, ",") != "进入详情,分享" {
+        t.Errorf("shop.html 的处理函数应去重为 [进入详情 分享]，得到 %v", shop.Handlers)
+    }
+    if len(shop.SourceFiles) != 2 {
+        t.Errorf("shop.html 的源文件应去重为2个，得到 %v", shop.SourceFiles)
+    }
+
+    home := rollups[1]
+    if home.Page != "home.html" || strings.Join(home.Handlers, ",") != "分享" {
+        t.Errorf("home.html 汇总不正确: %+v", home)
+    }
+
+    reportFile := filepath.Join(t.TempDir(), "by_page.txt")
+    if err := writePageRollup(reportFile, rollups); err != nil {
+        t.Fatalf("写入页面汇总失败: %v", err)
+    }
+    content, err := os.ReadFile(reportFile)
+    if err != nil {
+        t.Fatalf("读取页面汇总失败: %v", err)
+    }
+    lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+    if len(lines) != 3 {
+        t.Fatalf("页面汇总应有表头和2行，得到 %d 行", len(lines))
+    }
+    if lines[1] != "shop.html\t商城\t4\tshop.js; common.js\t进入详情; 分享" {
+        t.Errorf("shop.html 汇总行不正确: %q", lines[1])
+    }
+}
+
+// 测试演练模式统计按钮数、文件数和相关文件数，且不生成结果文件
+func TestDryRun(t *testing.T) {
+    projectDir := t.TempDir()
+    writeTestFiles(t, projectDir, map[string]string{
+        "shop.html":         "<div id='btn_buy'></div>",
+        "js/shop.js":        "addOperationsClickLog({button: 'btn_buy'})",
+        "js/shop_detail.js": "// detail",
+        "home.html":         "<div></div>",
+        "js/common.js":      "// common",
+    })
+
+    input := "button\tprojectcode\tpage\n" +
+        "btn_buy\tp1\twap/shop.html\n" +
+        "btn_share\tp1\twap/shop.html\n" +
+        "btn_home\tp1\twap/home.html\n" +
+        "btn_none\tp1\twap/missing.html\n"
+    buttonDataList, err := parseTsvFile(strings.NewReader(input))
+    if err != nil {
+        t.Fatalf("解析按钮数据失败: %v", err)
+    }
+    allFiles, err := collectAllFiles(projectDir)
+    if err != nil {
+        t.Fatalf("收集文件失败: %v", err)
+    }
+
+    // 在临时目录中运行，检查没有生成结果文件
+    workDir := t.TempDir()
+    oldDir, _ := os.Getwd()
+    if err := os.Chdir(workDir); err != nil {
+        t.Fatalf("切换目录失败: %v", err)
+    }
+    defer os.Chdir(oldDir)
+
+    var buf bytes.Buffer
+    report := reportDryRun(buttonDataList, allFiles, NewLogger(LogLevelInfo, &buf))
+
+    if report.Buttons != 4 || report.Files != 5 {
+        t.Errorf("应该统计到4个按钮和5个文件，得到 %d 个按钮和 %d 个文件", report.Buttons, report.Files)
+    }
+    expected := []int{3, 3, 1, 0}
+    for i, want := range expected {
+        if report.RelevantFiles[i] != want {
+            t.Errorf("按钮 %s 应有 %d 个相关文件，得到 %d 个", buttonDataList[i].Button, want, report.RelevantFiles[i])
+        }
+    }
+    if !strings.Contains(buf.String(), "演练完成: 4 个按钮, 5 个文件, 共 7 个相关文件") {
+        t.Errorf("演练日志缺少汇总信息:\n%s", buf.String())
+    }
+    if _, err := os.Stat(filepath.Join(workDir, "result.txt")); !os.IsNotExist(err) {
+        t.Errorf("演练模式不应生成结果文件")
+    }
+}
+
+// 测试 HTML 和 JS 文件按各自的匹配模式评分
+func TestExtensionSpecificPatterns(t *testing.T) {
+    tempDir := t.TempDir()
+    onclickLine := `<a onclick="track('btn_share')">分享</a>`
+    writeTestFiles(t, tempDir, map[string]string{
+        "page.html": onclickLine,
+        "page.js":   "var tpl = '" + onclickLine + "';",
+        "id.html":   `<div id="btn_buy">购买</div>`,
+        "id.js":     `var tpl = '<div id="btn_buy">购买</div>';`,
+        "click.js":  "addOperationsClickLog({button: 'btn_buy'})",
+    })
+
+    testCases := []struct {
+        file    string
+        button  string
+        quality int
+    }{
+        // HTML 中事件属性引用按钮是高优先级，JS 中同样的文本只是普通的函数参数
+        {"page.html", "btn_share", MatchQualityHigh},
+        {"page.js", "btn_share", MatchQualityMedium},
+        // id 属性只在 HTML 中作为按钮定义
+        {"id.html", "btn_buy", MatchQualityMedium},
+        {"id.js", "btn_buy", MatchQualityLow},
+        // JS 中的点击日志调用是高优先级
+        {"click.js", "btn_buy", MatchQualityHigh},
+    }
+
+    for _, tc := range testCases {
+        match, err := searchButtonInFile(filepath.Join(tempDir, tc.file), tc.button, "", map[string]string{}, SearchOptions{})
+        if err != nil {
+            t.Fatalf("搜索 %s 失败: %v", tc.file, err)
+        }
+        if match.Quality != tc.quality {
+            t.Errorf("%s 中 %s 的匹配质量应为 %d，得到 %d", tc.file, tc.button, tc.quality, match.Quality)
+        }
+    }
+}
+
+// 测试处理函数没有注释时沿调用链使用其他文件中函数的注释
+func TestFollowImports(t *testing.T) {
+    tempDir := t.TempDir()
+    writeTestFiles(t, tempDir, map[string]string{
+        "a.js": "function onBuyClick() {\n" +
+            "    addOperationsClickLog({button: 'btn_buy'});\n" +
+            "    submitOrder(1);\n" +
+            "}\n",
+        "lib/b.js": "// 提交订单\n" +
+            "function submitOrder(id) {\n" +
+            "    if (id) { ajaxPost(id); }\n" +
+            "}\n",
+    })
+
+    allFiles, err := collectAllFiles(tempDir)
+    if err != nil {
+        t.Fatalf("收集文件失败: %v", err)
+    }
+    logger := NewLogger(LogLevelError)
+    opts := SearchOptions{}
+    functionCommentMap := extractFunctionComments(allFiles, opts, logger)
+
+    // 不跟踪调用链时使用本文件的函数名
+    data := &ButtonData{Button: "btn_buy", Page: "a.html"}
+    searchButtonValueInAllFiles(data, allFiles, functionCommentMap, opts, logger)
+    if data.ButtonName != "onBuyClick" {
+        t.Errorf("未跟踪调用链时按钮名称应为函数名 onBuyClick，得到 %q", data.ButtonName)
+    }
+
+    opts.FollowDepth = 2
+    opts.Calls = extractFunctionCalls(allFiles, opts, logger)
+    if calls := opts.Calls["onBuyClick"]; strings.Join(calls, ",") != "addOperationsClickLog,submitOrder" {
+        t.Errorf("onBuyClick 的调用关系不正确: %v", calls)
+    }
+
+    data = &ButtonData{Button: "btn_buy", Page: "a.html"}
+    searchButtonValueInAllFiles(data, allFiles, functionCommentMap, opts, logger)
+    if data.ButtonName != "提交订单" {
+        t.Errorf("应使用 b.js 中 submitOrder 的注释，得到 %q", data.ButtonName)
+    }
+
+    // 超出深度限制时不继续查找
+    calls := map[string][]string{"a": {"b"}, "b": {"c"}, "c": {"d"}}
+    comments := map[string]string{"d": "深层注释"}
+    if comment, _ := resolveHandlerComment("a", calls, comments, 2); comment != "" {
+        t.Errorf("深度为2时不应找到第3层的注释，得到 %q", comment)
+    }
+    if comment, callee := resolveHandlerComment("a", calls, comments, 3); comment != "深层注释" || callee != "d" {
+        t.Errorf("深度为3时应找到 d 的注释，得到 %q (%s)", comment, callee)
+    }
+}
+
+// 测试默认输出列与原有表头一致，自定义列按给定顺序输出
+func TestOutputColumns(t *testing.T) {
+    buttonDataList := []ButtonData{
+        {Button: "btn_buy", ProjectCode: "p1", Page: "shop.html", ButtonValue: "buy()", ButtonName: "购买",
+            PageName: "商城", LineNumber: 2, SearchTime: 15 * time.Millisecond, SourceFile: "/src/shop.js"},
+    }
+    tempDir := t.TempDir()
+
+    columns, err := parseColumns(strings.Join(defaultColumns, ","))
+    if err != nil {
+        t.Fatalf("解析默认输出列失败: %v", err)
+    }
+    defaultFile := filepath.Join(tempDir, "default.txt")
+    if err := writeResultFile(defaultFile, buttonDataList, columns); err != nil {
+        t.Fatalf("写入结果文件失败: %v", err)
+    }
+    content, _ := os.ReadFile(defaultFile)
+    expected := "button\tprojectcode\tpage\t按钮值\t页面上按钮的名称\t页面名称\t源文件\t搜索耗时(ms)\n" +
+        "btn_buy\tp1\tshop.html\tbuy()\t购买\t商城\tshop.js\t15\n"
+    if string(content) != expected {
+        t.Errorf("默认输出应与原有格式一致，得到:\n%s", content)
+    }
+
+    columns, err = parseColumns("源文件, button,input_line")
+    if err != nil {
+        t.Fatalf("解析自定义输出列失败: %v", err)
+    }
+    customFile := filepath.Join(tempDir, "custom.txt")
+    if err := writeResultFile(customFile, buttonDataList, columns); err != nil {
+        t.Fatalf("写入结果文件失败: %v", err)
+    }
+    content, _ = os.ReadFile(customFile)
+    if string(content) != "源文件\tbutton\tinput_line\nshop.js\tbtn_buy\t2\n" {
+        t.Errorf("自定义输出列不正确，得到:\n%s", content)
+    }
+
+    if _, err := parseColumns("button,confidence"); err == nil {
+        t.Errorf("不支持的列应该报错")
+    }
+}
+
+// 测试匹配结果记录源文件中的行号，并可通过 source_line 列输出
+func TestSourceLineNumber(t *testing.T) {
+    tempDir := t.TempDir()
+    source := "// 分享按钮\n" +
+        "function share() {\n" +
+        "\n" +
+        "    addOperationsClickLog({button: 'btn_share'})\n" +
+        "}\n"
+    filePath := filepath.Join(tempDir, "share.js")
+    if err := os.WriteFile(filePath, []byte(source), 0644); err != nil {
+        t.Fatalf("创建测试文件失败: %v", err)
+    }
+
+    opts := SearchOptions{}
+    logger := NewLogger(LogLevelError)
+    comments := extractFunctionComments([]string{filePath}, opts, logger)
+    data := &ButtonData{Button: "btn_share", Page: "share.html", LineNumber: 7}
+    processButton(1, data, []string{filePath}, comments, opts, logger)
+    if data.ButtonValue == "" {
+        t.Fatalf("应找到按钮 btn_share 的匹配")
+    }
+    if data.SourceLine != 4 {
+        t.Errorf("匹配行应在源文件第 4 行，得到 %d", data.SourceLine)
+    }
+
+    columns, err := parseColumns("button,input_line,source_line")
+    if err != nil {
+        t.Fatalf("解析输出列失败: %v", err)
+    }
+    resultFile := filepath.Join(tempDir, "result.txt")
+    missing := ButtonData{Button: "btn_none", LineNumber: 8}
+    if err := writeResultFile(resultFile, []ButtonData{*data, missing}, columns); err != nil {
+        t.Fatalf("写入结果文件失败: %v", err)
+    }
+    content, _ := os.ReadFile(resultFile)
+    expected := "button\tinput_line\tsource_line\nbtn_share\t7\t4\nbtn_none\t8\t\n"
+    if string(content) != expected {
+        t.Errorf("source_line 列不正确，得到:\n%s", content)
+    }
+}
+
+// 测试通过 -log-funcs 指定的点击日志函数调用视为高质量匹配
+func TestCustomLogFuncs(t *testing.T) {
+    tempDir := t.TempDir()
+    writeTestFiles(t, tempDir, map[string]string{
+        "track.js":  "function buy() {\n    reportClick('btn_buy')\n}\n",
+        "object.js": "addTrack({button: 'btn_share', page: 'shop'})\n",
+    })
+
+    opts := SearchOptions{LogFuncs: parseLogFuncs("addTrack, reportClick")}
+    for file, button := range map[string]string{"track.js": "btn_buy", "object.js": "btn_share"} {
+        match, err := searchButtonInFile(filepath.Join(tempDir, file), button, "", map[string]string{}, opts)
+        if err != nil {
+            t.Fatalf("搜索 %s 失败: %v", file, err)
+        }
+        if match.Quality != MatchQualityHigh {
+            t.Errorf("%s 中自定义点击日志函数的调用应为高质量匹配，得到 %d", file, match.Quality)
+        }
+        if !opts.isClickLogCall(match.Line) {
+            t.Errorf("%s 的匹配行应计为点击日志调用: %q", file, match.Line)
+        }
+    }
+
+    // 使用默认函数名时 reportClick 的调用不是高优先级
+    match, _ := searchButtonInFile(filepath.Join(tempDir, "track.js"), "btn_buy", "", map[string]string{}, SearchOptions{})
+    if match.Quality == MatchQualityHigh {
+        t.Errorf("未指定 reportClick 时其调用不应为高质量匹配")
+    }
+}
+
+// 测试每个配置的点击日志函数使用相同的匹配规则，-object-arg-only 时直接传参的调用为中等质量
+func TestLogFuncsQuality(t *testing.T) {
+    tempDir := t.TempDir()
+    writeTestFiles(t, tempDir, map[string]string{
+        "object.js": "function share() {\n    addOperationsClickLog({button: 'btn_share', page: 'shop'})\n}\n",
+        "direct.js": "function buy() {\n    addOperationsClickLog('btn_buy')\n}\n",
+        "legacy.js": "function back() {\n    addOpeartionsClickLog('btn_back')\n}\n",
+        "custom.js": "function pay() {\n    reportClick('btn_pay')\n}\n",
+        "upper.js":  "function open() {\n    ReportClick('btn_open')\n}\n",
+    })
+
+    custom := parseLogFuncs("addOperationsClickLog,addOpeartionsClickLog,reportClick")
+    testCases := []struct {
+        name    string
+        opts    SearchOptions
+        file    string
+        button  string
+        quality int
+    }{
+        {"默认对象传参", SearchOptions{}, "object.js", "btn_share", MatchQualityHigh},
+        {"默认直接传参", SearchOptions{}, "direct.js", "btn_buy", MatchQualityHigh},
+        {"默认历史拼写直接传参", SearchOptions{}, "legacy.js", "btn_back", MatchQualityHigh},
+
+        // 默认函数与自定义函数的同一种调用质量相同
+        {"自定义列表中的默认函数", SearchOptions{LogFuncs: custom}, "direct.js", "btn_buy", MatchQualityHigh},
+        {"自定义函数", SearchOptions{LogFuncs: custom}, "custom.js", "btn_pay", MatchQualityHigh},
+        {"自定义函数大小写不同", SearchOptions{LogFuncs: custom}, "upper.js", "btn_open", MatchQualityHigh},
+
+        // 只有对象传参为高质量，与函数名无关
+        {"仅对象传参-对象", SearchOptions{ObjectArgOnly: true}, "object.js", "btn_share", MatchQualityHigh},
+        {"仅对象传参-默认函数", SearchOptions{ObjectArgOnly: true}, "direct.js", "btn_buy", MatchQualityMedium},
+        {"仅对象传参-历史拼写", SearchOptions{ObjectArgOnly: true}, "legacy.js", "btn_back", MatchQualityMedium},
+        {"仅对象传参-自定义函数", SearchOptions{LogFuncs: custom, ObjectArgOnly: true}, "custom.js", "btn_pay", MatchQualityMedium},
+    }
+    for _, tc := range testCases {
+        match, err := searchButtonInFile(filepath.Join(tempDir, tc.file), tc.button, "", map[string]string{}, tc.opts)
+        if err != nil {
+            t.Fatalf("%s: 搜索 %s 失败: %v", tc.name, tc.file, err)
+        }
+        if match.Quality != tc.quality {
+            t.Errorf("%s: %s 中 %s 的匹配质量应为 %d，得到 %d", tc.name, tc.file, tc.button, tc.quality, match.Quality)
+        }
+    }
+
+    // 点击日志调用的判断与匹配模式一样不区分大小写
+    opts := SearchOptions{LogFuncs: custom}
+    if !opts.isClickLogCall("ReportClick('btn_open')") || !opts.isClickLogCall("ADDOPERATIONSCLICKLOG({button: 'x'})") {
+        t.Error("点击日志调用的判断应不区分大小写")
+    }
+    if opts.isClickLogCall("report('btn_open')") {
+        t.Error("未配置的函数不应计为点击日志调用")
+    }
+}
@@ -1,14 +1,22 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings" // 新增导入
 	"sync"
+	"syscall"
 	"time"
 )
 
@@ -20,11 +28,33 @@ type User struct {
     CreatedAt time.Time `json:"created_at"`
 }
 
+// 邮箱格式：用户名@域名.后缀
+var emailRegex = regexp.MustCompile(`^[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}$`)
+
+// 校验用户数据，返回的错误说明是哪个字段不合法
+func (u User) Validate() error {
+    if strings.TrimSpace(u.Name) == "" {
+        return fmt.Errorf("name: 名称不能为空")
+    }
+    if !emailRegex.MatchString(u.Email) {
+        return fmt.Errorf("email: 邮箱格式不正确: %q", u.Email)
+    }
+    return nil
+}
+
+// 部分更新的字段，为 nil 的字段保持不变，ID 和创建时间不允许修改
+type UserPatch struct {
+    Name  *string `json:"name"`
+    Email *string `json:"email"`
+}
+
 // 响应包装器
 type ApiResponse struct {
     Success bool        `json:"success"`
     Data    interface{} `json:"data,omitempty"`
     Error   string      `json:"error,omitempty"`
+    Count   int         `json:"count,omitempty"` // 批量操作处理的数量
+    Total   *int        `json:"total,omitempty"` // 分页查询时满足条件的用户总数，只在列表响应中返回
 }
 
 // 简单的内存数据库
@@ -42,11 +72,98 @@ func NewUserStore() *UserStore {
     }
 }
 
+// 持久化文件的内容
+type storeFile struct {
+    NextID int    `json:"next_id"`
+    Users  []User `json:"users"`
+}
+
+// 将全部用户和下一个ID写入JSON文件，先写临时文件再重命名，避免写入中断损坏原文件
+func (s *UserStore) SaveToFile(path string) error {
+    s.RLock()
+    data := storeFile{NextID: s.nextID, Users: make([]User, 0, len(s.users))}
+    for _, user := range s.users {
+        data.Users = append(data.Users, user)
+    }
+    s.RUnlock()
+
+    sort.Slice(data.Users, func(i, j int) bool { return data.Users[i].ID < data.Users[j].ID })
+
+    content, err := json.MarshalIndent(data, "", "  ")
+    if err != nil {
+        return err
+    }
+
+    tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+    if err != nil {
+        return err
+    }
+    defer os.Remove(tmp.Name())
+    if _, err := tmp.Write(content); err != nil {
+        tmp.Close()
+        return err
+    }
+    if err := tmp.Close(); err != nil {
+        return err
+    }
+    return os.Rename(tmp.Name(), path)
+}
+
+// 从JSON文件读取用户，文件不存在时保持为空
+// 下一个ID取文件中记录的值和最大ID+1中的较大者，避免新用户的ID与已有用户冲突
+func (s *UserStore) LoadFromFile(path string) error {
+    content, err := os.ReadFile(path)
+    if errors.Is(err, os.ErrNotExist) {
+        return nil
+    }
+    if err != nil {
+        return err
+    }
+
+    var data storeFile
+    if err := json.Unmarshal(content, &data); err != nil {
+        return fmt.Errorf("解析数据文件 %s 失败: %v", path, err)
+    }
+
+    s.Lock()
+    defer s.Unlock()
+
+    s.users = make(map[int]User, len(data.Users))
+    s.nextID = data.NextID
+    for _, user := range data.Users {
+        s.users[user.ID] = user
+        if user.ID >= s.nextID {
+            s.nextID = user.ID + 1
+        }
+    }
+    if s.nextID < 1 {
+        s.nextID = 1
+    }
+    return nil
+}
+
 // 创建用户
 func (s *UserStore) Create(user User) User {
     s.Lock()
     defer s.Unlock()
 
+    return s.create(user)
+}
+
+// 在同一次加锁中批量创建用户，返回分配了ID的用户列表
+func (s *UserStore) CreateBatch(users []User) []User {
+    s.Lock()
+    defer s.Unlock()
+
+    created := make([]User, 0, len(users))
+    for _, user := range users {
+        created = append(created, s.create(user))
+    }
+    return created
+}
+
+// 分配ID并保存用户，调用方需持有写锁
+func (s *UserStore) create(user User) User {
     user.ID = s.nextID
     user.CreatedAt = time.Now()
     s.users[user.ID] = user
@@ -67,6 +184,64 @@ func (s *UserStore) GetAll() []User {
     return users
 }
 
+// 用户查询条件，为空的条件不参与过滤
+type UserCriteria struct {
+    Email        string // 邮箱完全匹配，不区分大小写
+    NameContains string // 名称包含该字符串，不区分大小写
+}
+
+// 查找满足全部条件的用户
+func (s *UserStore) Search(criteria UserCriteria) []User {
+    s.RLock()
+    defer s.RUnlock()
+
+    nameContains := strings.ToLower(criteria.NameContains)
+    users := make([]User, 0)
+    for _, user := range s.users {
+        if criteria.Email != "" && !strings.EqualFold(user.Email, criteria.Email) {
+            continue
+        }
+        if nameContains != "" && !strings.Contains(strings.ToLower(user.Name), nameContains) {
+            continue
+        }
+        users = append(users, user)
+    }
+    return users
+}
+
+// 过滤并按字段排序后分页获取用户，返回当前页和满足条件的用户总数，limit 为 0 时返回 offset 之后的全部用户
+func (s *UserStore) GetPaged(criteria UserCriteria, limit, offset int, sortBy string, desc bool) ([]User, int) {
+    users := s.Search(criteria)
+
+    sort.Slice(users, func(i, j int) bool {
+        a, b := users[i], users[j]
+        if desc {
+            a, b = b, a
+        }
+        switch sortBy {
+        case "name":
+            if a.Name != b.Name {
+                return a.Name < b.Name
+            }
+        case "created_at":
+            if !a.CreatedAt.Equal(b.CreatedAt) {
+                return a.CreatedAt.Before(b.CreatedAt)
+            }
+        }
+        return a.ID < b.ID
+    })
+
+    total := len(users)
+    if offset > total {
+        offset = total
+    }
+    end := total
+    if limit > 0 && offset+limit < total {
+        end = offset + limit
+    }
+    return users[offset:end], total
+}
+
 // 根据ID获取用户
 func (s *UserStore) GetByID(id int) (User, bool) {
     s.RLock()
@@ -94,6 +269,31 @@ func (s *UserStore) Update(id int, user User) (User, bool) {
     return user, true
 }
 
+// 只更新提供的字段，合并后的用户校验失败时不做修改
+// 返回合并后的用户、用户是否存在以及校验错误
+func (s *UserStore) Patch(id int, patch UserPatch) (User, bool, error) {
+    s.Lock()
+    defer s.Unlock()
+
+    user, exists := s.users[id]
+    if !exists {
+        return User{}, false, nil
+    }
+
+    if patch.Name != nil {
+        user.Name = *patch.Name
+    }
+    if patch.Email != nil {
+        user.Email = *patch.Email
+    }
+    if err := user.Validate(); err != nil {
+        return User{}, true, err
+    }
+    s.users[id] = user
+
+    return user, true, nil
+}
+
 // 删除用户
 func (s *UserStore) Delete(id int) bool {
     s.Lock()
@@ -115,20 +315,8 @@ func loggingMiddleware(next http.Handler) http.Handler {
     })
 }
 
-func main() {
-    // 命令行参数
-    port := flag.Int("port", 8080, "API服务器端口")
-    flag.Parse()
-
-    // 初始化数据存储
-    store := NewUserStore()
-
-    // 添加一些示例数据
-    store.Create(User{Name: "张三", Email: "zhang@example.com"})
-    store.Create(User{Name: "李四", Email: "li@example.com"})
-    store.Create(User{Name: "王五", Email: "wang@example.com"})
-
-    // 创建路由
+// 创建用户接口的路由
+func newRouter(store *UserStore) *http.ServeMux {
     mux := http.NewServeMux()
 
     // 处理 /users 路由（获取所有用户和创建用户）
@@ -141,9 +329,41 @@ func main() {
         
         switch r.Method {
         case http.MethodGet:
-            // 获取所有用户
-            users := store.GetAll()
-            sendJSON(w, ApiResponse{Success: true, Data: users})
+            // 过滤并分页获取用户
+            query := r.URL.Query()
+            limit, err := queryInt(query.Get("limit"))
+            if err != nil {
+                sendError(w, "无效的 limit 参数", http.StatusBadRequest)
+                return
+            }
+            offset, err := queryInt(query.Get("offset"))
+            if err != nil {
+                sendError(w, "无效的 offset 参数", http.StatusBadRequest)
+                return
+            }
+            
+            sortBy := query.Get("sort")
+            switch sortBy {
+            case "":
+                sortBy = "id"
+            case "id", "name", "created_at":
+            default:
+                sendError(w, "sort 参数只支持 id, name, created_at", http.StatusBadRequest)
+                return
+            }
+            
+            order := query.Get("order")
+            if order != "" && order != "asc" && order != "desc" {
+                sendError(w, "order 参数只支持 asc, desc", http.StatusBadRequest)
+                return
+            }
+            
+            criteria := UserCriteria{
+                Email:        query.Get("email"),
+                NameContains: query.Get("name_contains"),
+            }
+            users, total := store.GetPaged(criteria, limit, offset, sortBy, order == "desc")
+            sendJSON(w, ApiResponse{Success: true, Data: users, Total: &total})
             
         case http.MethodPost:
             // 创建用户
@@ -152,6 +372,10 @@ func main() {
                 sendError(w, "无效的请求数据", http.StatusBadRequest)
                 return
             }
+            if err := user.Validate(); err != nil {
+                sendError(w, err.Error(), http.StatusBadRequest)
+                return
+            }
             
             createdUser := store.Create(user)
             sendJSON(w, ApiResponse{Success: true, Data: createdUser})
@@ -161,7 +385,34 @@ func main() {
         }
     })
 
-    // 处理 /users/{id} 路由（获取、更新、删除单个用户）
+    // 处理 /users/batch 路由（批量创建用户）
+    mux.HandleFunc("/users/batch", func(w http.ResponseWriter, r *http.Request) {
+        if r.Method != http.MethodPost {
+            http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+            return
+        }
+        
+        var users []User
+        if err := json.NewDecoder(r.Body).Decode(&users); err != nil {
+            sendError(w, "无效的请求数据", http.StatusBadRequest)
+            return
+        }
+        if len(users) == 0 {
+            sendError(w, "用户列表不能为空", http.StatusBadRequest)
+            return
+        }
+        for i, user := range users {
+            if err := user.Validate(); err != nil {
+                sendError(w, fmt.Sprintf("第 %d 个用户 %v", i+1, err), http.StatusBadRequest)
+                return
+            }
+        }
+        
+        createdUsers := store.CreateBatch(users)
+        sendJSON(w, ApiResponse{Success: true, Data: createdUsers, Count: len(createdUsers)})
+    })
+
+    // 处理 /users/{id} 路由（获取、更新、部分更新、删除单个用户）
     mux.HandleFunc("/users/", func(w http.ResponseWriter, r *http.Request) {
         // 从路径中提取ID
         pathParts := strings.Split(r.URL.Path, "/")
@@ -195,6 +446,10 @@ func main() {
                 sendError(w, "无效的请求数据", http.StatusBadRequest)
                 return
             }
+            if err := user.Validate(); err != nil {
+                sendError(w, err.Error(), http.StatusBadRequest)
+                return
+            }
             
             updatedUser, exists := store.Update(id, user)
             if !exists {
@@ -204,6 +459,26 @@ func main() {
             
             sendJSON(w, ApiResponse{Success: true, Data: updatedUser})
             
+        case http.MethodPatch:
+            // 部分更新用户
+            var patch UserPatch
+            if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+                sendError(w, "无效的请求数据", http.StatusBadRequest)
+                return
+            }
+            
+            patchedUser, exists, err := store.Patch(id, patch)
+            if !exists {
+                sendError(w, "用户不存在", http.StatusNotFound)
+                return
+            }
+            if err != nil {
+                sendError(w, err.Error(), http.StatusBadRequest)
+                return
+            }
+            
+            sendJSON(w, ApiResponse{Success: true, Data: patchedUser})
+            
         case http.MethodDelete:
             // 删除用户
             success := store.Delete(id)
@@ -219,13 +494,76 @@ func main() {
         }
     })
 
-    // 应用中间件
-    handler := loggingMiddleware(mux)
+    return mux
+}
+
+func main() {
+    // 命令行参数
+    port := flag.Int("port", 8080, "API服务器端口")
+    dataFile := flag.String("data", "", "用户数据文件，启动时读取，退出时保存(为空时只保存在内存中)")
+    flag.Parse()
+
+    // 初始化数据存储
+    store := NewUserStore()
+
+    if *dataFile != "" {
+        if err := store.LoadFromFile(*dataFile); err != nil {
+            log.Fatalf("读取数据文件失败: %v", err)
+        }
+    } else {
+        // 添加一些示例数据
+        store.Create(User{Name: "张三", Email: "zhang@example.com"})
+        store.Create(User{Name: "李四", Email: "li@example.com"})
+        store.Create(User{Name: "王五", Email: "wang@example.com"})
+    }
+
+    // 创建路由并应用中间件
+    handler := loggingMiddleware(newRouter(store))
 
     // 启动服务器
     addr := fmt.Sprintf(":%d", *port)
+    server := &http.Server{Addr: addr, Handler: handler}
+
+    // 收到 Ctrl+C 或 SIGTERM 后停止接收请求，等待进行中的请求完成再保存数据
+    ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+    defer stop()
+    shutdownDone := make(chan struct{})
+    go func() {
+        defer close(shutdownDone)
+        <-ctx.Done()
+        shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+        defer cancel()
+        if err := server.Shutdown(shutdownCtx); err != nil {
+            log.Printf("关闭服务器失败，部分请求未完成: %v", err)
+        }
+    }()
+
     fmt.Printf("API 服务器启动在 http://localhost%s\n", addr)
-    log.Fatal(http.ListenAndServe(addr, handler))
+    if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+        log.Fatal(err)
+    }
+
+    // Shutdown 开始时 ListenAndServe 就会返回，等待进行中的请求处理完
+    <-shutdownDone
+
+    if *dataFile != "" {
+        if err := store.SaveToFile(*dataFile); err != nil {
+            log.Fatalf("保存数据文件失败: %v", err)
+        }
+        fmt.Printf("用户数据已保存到: %s\n", *dataFile)
+    }
+}
+
+// 辅助函数：解析非负整数查询参数，为空时返回 0
+func queryInt(value string) (int, error) {
+    if value == "" {
+        return 0, nil
+    }
+    n, err := strconv.Atoi(value)
+    if err != nil || n < 0 {
+        return 0, fmt.Errorf("无效的参数: %s", value)
+    }
+    return n, nil
 }
 
 // 辅助函数：发送JSON响应
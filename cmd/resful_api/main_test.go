@@ -0,0 +1,391 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// 解码后的接口响应，data 保持原始JSON以便按需解析
+type testResponse struct {
+    Success bool            `json:"success"`
+    Data    json.RawMessage `json:"data"`
+    Error   string          `json:"error"`
+    Count   int             `json:"count"`
+    Total   *int            `json:"total"`
+}
+
+// 向路由发送请求并解码响应
+func doRequest(t *testing.T, handler http.Handler, method, path, body string) (int, testResponse) {
+    t.Helper()
+    req := httptest.NewRequest(method, path, strings.NewReader(body))
+    rec := httptest.NewRecorder()
+    handler.ServeHTTP(rec, req)
+
+    var resp testResponse
+    if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+        t.Fatalf("%s %s 返回的不是JSON: %q", method, path, rec.Body.String())
+    }
+    return rec.Code, resp
+}
+
+// 创建包含给定名称用户的存储，ID 按顺序从 1 开始
+func newTestStore(names ...string) *UserStore {
+    store := NewUserStore()
+    for _, name := range names {
+        store.Create(User{Name: name, Email: strings.ToLower(name) + "@example.com"})
+    }
+    return store
+}
+
+// 测试分页和排序：当前页、总数、超出范围的 offset 以及无效的参数
+func TestGetUsersPaging(t *testing.T) {
+    handler := newRouter(newTestStore("Carol", "Alice", "Bob", "Dave"))
+
+    code, resp := doRequest(t, handler, http.MethodGet, "/users?sort=name&order=desc&limit=2&offset=1", "")
+    if code != http.StatusOK {
+        t.Fatalf("分页查询应返回 200，得到 %d: %s", code, resp.Error)
+    }
+    var users []User
+    if err := json.Unmarshal(resp.Data, &users); err != nil {
+        t.Fatalf("解析用户列表失败: %v", err)
+    }
+    if len(users) != 2 || users[0].Name != "Carol" || users[1].Name != "Bob" {
+        t.Errorf("按名称降序的第二页应为 Carol, Bob，得到 %v", users)
+    }
+    if resp.Total == nil || *resp.Total != 4 {
+        t.Errorf("总数应为 4，得到 %v", resp.Total)
+    }
+
+    // offset 超出范围时返回空列表，总数不变
+    code, resp = doRequest(t, handler, http.MethodGet, "/users?offset=10", "")
+    if code != http.StatusOK {
+        t.Fatalf("offset 超出范围应返回 200，得到 %d", code)
+    }
+    if string(resp.Data) != "[]" {
+        t.Errorf("offset 超出范围时应返回空列表，得到 %s", resp.Data)
+    }
+    if resp.Total == nil || *resp.Total != 4 {
+        t.Errorf("offset 超出范围时总数仍应为 4，得到 %v", resp.Total)
+    }
+
+    // 没有匹配的用户时总数为 0 也要返回
+    _, resp = doRequest(t, handler, http.MethodGet, "/users?email=nobody@example.com", "")
+    if resp.Total == nil || *resp.Total != 0 {
+        t.Errorf("没有匹配时应返回总数 0，得到 %v", resp.Total)
+    }
+
+    // 单个用户的响应不包含总数
+    _, resp = doRequest(t, handler, http.MethodGet, "/users/1", "")
+    if resp.Total != nil {
+        t.Errorf("单个用户的响应不应包含总数，得到 %d", *resp.Total)
+    }
+
+    for _, path := range []string{
+        "/users?limit=-1",
+        "/users?offset=abc",
+        "/users?sort=email",
+        "/users?order=up",
+    } {
+        if code, resp := doRequest(t, handler, http.MethodGet, path, ""); code != http.StatusBadRequest || resp.Success {
+            t.Errorf("%s 应返回 400，得到 %d", path, code)
+        }
+    }
+}
+
+// 测试保存后重新读取得到相同的用户，且新用户不会复用已有或已删除的ID
+func TestUserStoreFileRoundTrip(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "users.json")
+    store := newTestStore("Alice", "Bob", "Carol")
+    store.Delete(3)
+    if err := store.SaveToFile(path); err != nil {
+        t.Fatalf("保存数据文件失败: %v", err)
+    }
+
+    loaded := NewUserStore()
+    if err := loaded.LoadFromFile(path); err != nil {
+        t.Fatalf("读取数据文件失败: %v", err)
+    }
+    for _, id := range []int{1, 2} {
+        want, _ := store.GetByID(id)
+        got, ok := loaded.GetByID(id)
+        if !ok || got.Name != want.Name || got.Email != want.Email || !got.CreatedAt.Equal(want.CreatedAt) {
+            t.Errorf("用户 %d 读取后应为 %+v，得到 %+v", id, want, got)
+        }
+    }
+    if _, ok := loaded.GetByID(3); ok {
+        t.Error("已删除的用户不应被读取")
+    }
+
+    // 已删除用户的ID也不复用
+    if user := loaded.Create(User{Name: "Dave", Email: "dave@example.com"}); user.ID != 4 {
+        t.Errorf("新用户的ID应为 4，得到 %d", user.ID)
+    }
+}
+
+// 测试文件中的 next_id 小于已有用户的ID时，按最大ID继续分配
+func TestUserStoreLoadRestoresNextID(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "users.json")
+    content := `{"next_id": 2, "users": [{"id": 1, "name": "Alice", "email": "alice@example.com"}, {"id": 7, "name": "Bob", "email": "bob@example.com"}]}`
+    if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+        t.Fatalf("写入数据文件失败: %v", err)
+    }
+
+    store := NewUserStore()
+    if err := store.LoadFromFile(path); err != nil {
+        t.Fatalf("读取数据文件失败: %v", err)
+    }
+    if user := store.Create(User{Name: "Carol", Email: "carol@example.com"}); user.ID != 8 {
+        t.Errorf("新用户的ID应为 8，得到 %d", user.ID)
+    }
+    if user, _ := store.GetByID(7); user.Name != "Bob" {
+        t.Errorf("已有用户不应被覆盖，得到 %+v", user)
+    }
+}
+
+// 测试数据文件不存在时保持为空，文件损坏时报错且不修改已有数据
+func TestUserStoreLoadMissingAndCorrupt(t *testing.T) {
+    dir := t.TempDir()
+
+    store := NewUserStore()
+    if err := store.LoadFromFile(filepath.Join(dir, "missing.json")); err != nil {
+        t.Fatalf("文件不存在时不应报错: %v", err)
+    }
+    if users := store.GetAll(); len(users) != 0 {
+        t.Errorf("文件不存在时应为空，得到 %v", users)
+    }
+    if user := store.Create(User{Name: "Alice", Email: "alice@example.com"}); user.ID != 1 {
+        t.Errorf("空存储的第一个用户ID应为 1，得到 %d", user.ID)
+    }
+
+    corrupt := filepath.Join(dir, "corrupt.json")
+    if err := os.WriteFile(corrupt, []byte(`{"next_id": 5, "users": [`), 0644); err != nil {
+        t.Fatalf("写入数据文件失败: %v", err)
+    }
+    if err := store.LoadFromFile(corrupt); err == nil {
+        t.Error("文件损坏时应报错")
+    }
+    if users := store.GetAll(); len(users) != 1 || users[0].Name != "Alice" {
+        t.Errorf("读取失败时不应修改已有数据，得到 %v", users)
+    }
+}
+
+// 测试 PATCH 只修改提供的字段，ID 和创建时间保持不变
+func TestPatchUser(t *testing.T) {
+    store := newTestStore("Alice", "Bob")
+    handler := newRouter(store)
+    before, _ := store.GetByID(1)
+
+    code, resp := doRequest(t, handler, http.MethodPatch, "/users/1",
+        `{"name": "Alicia", "id": 99, "created_at": "2000-01-01T00:00:00Z"}`)
+    if code != http.StatusOK {
+        t.Fatalf("部分更新应返回 200，得到 %d: %s", code, resp.Error)
+    }
+    var patched User
+    if err := json.Unmarshal(resp.Data, &patched); err != nil {
+        t.Fatalf("解析用户失败: %v", err)
+    }
+    if patched.Name != "Alicia" || patched.Email != before.Email {
+        t.Errorf("应只修改名称，得到 %+v", patched)
+    }
+    if patched.ID != 1 || !patched.CreatedAt.Equal(before.CreatedAt) {
+        t.Errorf("ID 和创建时间不应改变，得到 %+v", patched)
+    }
+    if stored, _ := store.GetByID(1); stored.Name != patched.Name || stored.Email != patched.Email {
+        t.Errorf("存储的用户应为合并后的结果，得到 %+v", stored)
+    }
+    if other, _ := store.GetByID(2); other.Name != "Bob" {
+        t.Errorf("其他用户不应被修改，得到 %+v", other)
+    }
+
+    // 只修改邮箱
+    if code, _ := doRequest(t, handler, http.MethodPatch, "/users/1", `{"email": "alicia@example.com"}`); code != http.StatusOK {
+        t.Fatalf("修改邮箱应返回 200，得到 %d", code)
+    }
+    if stored, _ := store.GetByID(1); stored.Name != "Alicia" || stored.Email != "alicia@example.com" {
+        t.Errorf("修改邮箱后名称应保持不变，得到 %+v", stored)
+    }
+}
+
+// 测试合并后校验失败时返回 400 且不修改用户，用户不存在时返回 404
+func TestPatchUserErrors(t *testing.T) {
+    store := newTestStore("Alice")
+    handler := newRouter(store)
+    before, _ := store.GetByID(1)
+
+    for _, body := range []string{
+        `{"name": "Alicia", "email": "not-an-email"}`,
+        `{"name": "  "}`,
+        `{"name": `,
+    } {
+        code, resp := doRequest(t, handler, http.MethodPatch, "/users/1", body)
+        if code != http.StatusBadRequest || resp.Success {
+            t.Errorf("%s 应返回 400，得到 %d", body, code)
+        }
+        if stored, _ := store.GetByID(1); stored != before {
+            t.Errorf("%s 校验失败后用户不应改变，得到 %+v", body, stored)
+        }
+    }
+
+    code, resp := doRequest(t, handler, http.MethodPatch, "/users/42", `{"name": "Nobody"}`)
+    if code != http.StatusNotFound || resp.Error != "用户不存在" {
+        t.Errorf("用户不存在时应返回 404，得到 %d: %s", code, resp.Error)
+    }
+    if _, exists := store.GetByID(42); exists {
+        t.Error("部分更新不应创建新用户")
+    }
+}
+
+// 测试批量创建：ID 连续、返回数量，任一用户无效时整批拒绝
+func TestCreateBatch(t *testing.T) {
+    store := newTestStore("Alice")
+    handler := newRouter(store)
+
+    code, resp := doRequest(t, handler, http.MethodPost, "/users/batch",
+        `[{"name": "Bob", "email": "bob@example.com"}, {"name": "Carol", "email": "carol@example.com"}, {"name": "Dave", "email": "dave@example.com"}]`)
+    if code != http.StatusOK || !resp.Success {
+        t.Fatalf("批量创建应返回 200，得到 %d: %s", code, resp.Error)
+    }
+    var created []User
+    if err := json.Unmarshal(resp.Data, &created); err != nil {
+        t.Fatalf("解析用户列表失败: %v", err)
+    }
+    if resp.Count != 3 || len(created) != 3 {
+        t.Fatalf("应返回创建的数量 3，得到 count=%d, %d 个用户", resp.Count, len(created))
+    }
+    for i, user := range created {
+        if user.ID != i+2 {
+            t.Errorf("第 %d 个用户的ID应为 %d，得到 %d", i+1, i+2, user.ID)
+        }
+    }
+    // 任一用户校验失败时整批不创建
+    code, resp = doRequest(t, handler, http.MethodPost, "/users/batch",
+        `[{"name": "Erin", "email": "erin@example.com"}, {"name": "Frank", "email": "bad"}]`)
+    if code != http.StatusBadRequest || !strings.Contains(resp.Error, "第 2 个用户") || !strings.Contains(resp.Error, "email") {
+        t.Errorf("第2个用户无效时应返回 400 并指出用户和字段，得到 %d: %s", code, resp.Error)
+    }
+    if users := store.GetAll(); len(users) != 4 {
+        t.Errorf("整批被拒绝时不应创建任何用户，现有 %d 个用户", len(users))
+    }
+    if user := store.Create(User{Name: "Grace", Email: "grace@example.com"}); user.ID != 5 {
+        t.Errorf("被拒绝的批次不应占用ID，新用户ID应为 5，得到 %d", user.ID)
+    }
+
+    for _, body := range []string{`[]`, `{"name": "Bob"}`} {
+        if code, resp := doRequest(t, handler, http.MethodPost, "/users/batch", body); code != http.StatusBadRequest || resp.Success {
+            t.Errorf("%s 应返回 400，得到 %d", body, code)
+        }
+    }
+
+    req := httptest.NewRequest(http.MethodGet, "/users/batch", nil)
+    rec := httptest.NewRecorder()
+    handler.ServeHTTP(rec, req)
+    if rec.Code != http.StatusMethodNotAllowed {
+        t.Errorf("GET /users/batch 应返回 405，得到 %d", rec.Code)
+    }
+}
+
+// 测试创建和更新时校验用户数据，返回 400 并指出不合法的字段
+func TestValidateOnCreateAndUpdate(t *testing.T) {
+    testCases := []struct {
+        name  string
+        body  string
+        field string
+    }{
+        {"空名称", `{"name": "", "email": "a@example.com"}`, "name"},
+        {"空白名称", `{"name": "   ", "email": "a@example.com"}`, "name"},
+        {"缺少@", `{"name": "Alice", "email": "alice.example.com"}`, "email"},
+        {"缺少域名后缀", `{"name": "Alice", "email": "alice@example"}`, "email"},
+        {"空邮箱", `{"name": "Alice", "email": ""}`, "email"},
+    }
+
+    for _, method := range []string{http.MethodPost, http.MethodPut} {
+        path := "/users"
+        if method == http.MethodPut {
+            path = "/users/1"
+        }
+        for _, tc := range testCases {
+            store := newTestStore("Bob")
+            before, _ := store.GetByID(1)
+
+            code, resp := doRequest(t, newRouter(store), method, path, tc.body)
+            if code != http.StatusBadRequest || resp.Success {
+                t.Errorf("%s %s: 应返回 400，得到 %d", method, tc.name, code)
+            }
+            if !strings.HasPrefix(resp.Error, tc.field+":") {
+                t.Errorf("%s %s: 错误信息应指出字段 %s，得到 %q", method, tc.name, tc.field, resp.Error)
+            }
+            if users := store.GetAll(); len(users) != 1 || users[0] != before {
+                t.Errorf("%s %s: 校验失败时不应修改数据，得到 %v", method, tc.name, users)
+            }
+        }
+    }
+
+    // 合法的数据正常创建
+    code, resp := doRequest(t, newRouter(NewUserStore()), http.MethodPost, "/users", `{"name": "Alice", "email": "alice@example.com"}`)
+    if code != http.StatusOK || !resp.Success {
+        t.Errorf("合法的用户应创建成功，得到 %d: %s", code, resp.Error)
+    }
+}
+
+// 测试按名称和邮箱过滤：均不区分大小写，先过滤再分页，总数为过滤后的数量
+func TestSearchUsers(t *testing.T) {
+    store := NewUserStore()
+    for _, user := range []User{
+        {Name: "Alice Smith", Email: "alice@example.com"},
+        {Name: "Bob", Email: "Bob@Example.com"},
+        {Name: "alison", Email: "alison@example.com"},
+        {Name: "Carol", Email: "carol@example.com"},
+        {Name: "MALIK", Email: "malik@example.com"},
+    } {
+        store.Create(user)
+    }
+    handler := newRouter(store)
+
+    names := func(resp testResponse) []string {
+        t.Helper()
+        var users []User
+        if err := json.Unmarshal(resp.Data, &users); err != nil {
+            t.Fatalf("解析用户列表失败: %v", err)
+        }
+        result := make([]string, len(users))
+        for i, user := range users {
+            result[i] = user.Name
+        }
+        return result
+    }
+
+    testCases := []struct {
+        path  string
+        names string
+        total int
+    }{
+        // name_contains 不区分大小写
+        {"/users?name_contains=ALI", "Alice Smith,alison,MALIK", 3},
+        // email 完全匹配且不区分大小写，部分匹配不算
+        {"/users?email=bob@example.COM", "Bob", 1},
+        {"/users?email=example.com", "", 0},
+        // 先过滤再分页，总数为过滤后的数量
+        {"/users?name_contains=ali&limit=2", "Alice Smith,alison", 3},
+        {"/users?name_contains=ali&limit=2&offset=2", "MALIK", 3},
+        {"/users?name_contains=ali&offset=5", "", 3},
+        // 条件同时生效
+        {"/users?name_contains=ali&email=ALISON@example.com&limit=1", "alison", 1},
+    }
+    for _, tc := range testCases {
+        code, resp := doRequest(t, handler, http.MethodGet, tc.path, "")
+        if code != http.StatusOK {
+            t.Fatalf("%s 应返回 200，得到 %d: %s", tc.path, code, resp.Error)
+        }
+        if got := strings.Join(names(resp), ","); got != tc.names {
+            t.Errorf("%s 应返回 %q，得到 %q", tc.path, tc.names, got)
+        }
+        if resp.Total == nil || *resp.Total != tc.total {
+            t.Errorf("%s 的总数应为 %d，得到 %v", tc.path, tc.total, resp.Total)
+        }
+    }
+}
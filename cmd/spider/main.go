@@ -1,10 +1,24 @@
 package main
 
 import (
+    "bufio"
+    "bytes"
+    "context"
+    "encoding/json"
+    "encoding/xml"
+    "errors"
     "flag"
     "fmt"
+    "hash/fnv"
+    "io"
+    "math/rand"
     "net/url"
     "os"
+    "os/signal"
+    "path"
+    "path/filepath"
+    "regexp"
+    "sort"
     "strings"
     "sync"
     "time"
@@ -15,21 +29,109 @@ import (
 
 // 爬虫配置
 type CrawlerConfig struct {
-    StartURL   string
-    MaxDepth   int
-    MaxURLs    int
-    SameHost   bool
-    Timeout    time.Duration
-    Concurrent int
+    StartURL        string
+    MaxDepth        int
+    MaxURLs         int            // 队列中最多容纳的 URL 数
+    MaxPages        int            // 实际爬取并记录的页面数，0 表示与 MaxURLs 相同
+    SameHost        bool
+    Timeout         time.Duration
+    Concurrent      int
+    MaxLinksPerPage int            // 单个页面最多加入队列的链接数，0 表示不限制
+    Delay           time.Duration  // 同一主机请求之间的最小间隔
+    Jitter          float64        // 在最小间隔之上随机增加的比例，如 0.3 表示增加 0~30%
+    AllowHosts      []string       // 允许爬取的主机，为空时不限制
+    DenyHosts       []string       // 禁止爬取的主机
+    Include         *regexp.Regexp // 发现的链接必须匹配该正则，为 nil 时不限制
+    Exclude         *regexp.Regexp // 匹配该正则的链接不加入队列
+    FormURL         string         // 提交表单作为种子页面的地址
+    FormMethod      string         // 表单提交方式 GET 或 POST
+    FormFields      url.Values     // 表单字段
+    Cache           *pageCache     // 上次爬取记录的页面校验信息，为 nil 时不发送条件请求
+    Robots          *robotsCache   // 按主机缓存的 robots.txt 规则，为 nil 时忽略 robots.txt
+    Retries         int            // 网络错误或 5xx 时的重试次数
+    RetryBackoff    time.Duration  // 首次重试前的等待时间，之后每次翻倍
+    Budget          float64        // 加权爬取预算，HTML 页面计 1，0 表示不限制
+    AssetCost       float64        // 非 HTML 资源消耗的预算
+    SaveDir         string         // 保存 200 响应正文的目录，为空时不保存
+    UserAgent       string         // 请求使用的 User-Agent
+    Headers         http.Header    // 附加到每个请求的请求头
+    OutputFile      string         // 定期写入结果快照的文件
+    OutputEvery     int            // 每爬取多少个页面写入一次快照，0 表示不写入
+}
+
+// 礼貌爬取预设，统一设置并发、间隔和单页链接上限
+type crawlProfile struct {
+    Concurrent      int
+    Delay           time.Duration
+    Jitter          float64
+    MaxLinksPerPage int
+}
+
+// 可用的预设:
+//   gentle     单协程，间隔 2s~3s，单页最多 20 个链接
+//   normal     5 个协程，间隔 500ms~650ms，单页链接不限
+//   aggressive 20 个协程，无间隔，单页链接不限
+var crawlProfiles = map[string]crawlProfile{
+    "gentle":     {Concurrent: 1, Delay: 2 * time.Second, Jitter: 0.5, MaxLinksPerPage: 20},
+    "normal":     {Concurrent: 5, Delay: 500 * time.Millisecond, Jitter: 0.3},
+    "aggressive": {Concurrent: 20},
+}
+
+// 中断爬取后等待进行中的请求完成的最长时间
+const shutdownGrace = 3 * time.Second
+
+// 默认使用常见浏览器的 User-Agent，避免被部分网站拒绝
+const defaultUserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0 Safari/537.36"
+
+// 可重复指定的字符串参数
+type stringList []string
+
+func (l *stringList) String() string {
+    return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+    *l = append(*l, value)
+    return nil
 }
 
 // 页面数据
 type PageData struct {
-    URL      string
-    Title    string
-    Links    []string
-    Depth    int
-    Error    error
+    URL          string
+    Parent       string // 发现该页面的页面，起始页为空
+    Title        string
+    Description  string   // meta description
+    Headings     []string // <h1> 标题文本
+    Images       []string // 图片地址，已解析为绝对 URL
+    Links        []string
+    Depth        int
+    Error        error
+    Unchanged    bool   // 条件请求返回 304，标题和链接沿用上次爬取的结果
+    LastModified string // 响应的 Last-Modified 头
+    Attempts     int    // 请求次数，包括重试
+    ContentType  string // 响应的 Content-Type 头
+    SavedPath    string // 响应正文保存的文件路径
+    Bytes        int64  // 实际读取的响应正文字节数
+    BaseHref     string // 页面 <base href> 声明的绝对地址，相对链接以此为基准解析
+    StatusCode   int    // 响应状态码，请求失败时为 0
+}
+
+// 上次爬取时记录的页面校验信息和解析结果
+type cacheEntry struct {
+    LastModified string   `json:"last_modified,omitempty"`
+    ETag         string   `json:"etag,omitempty"`
+    Title        string   `json:"title"`
+    Links        []string `json:"links"`
+    BaseHref     string   `json:"base_href,omitempty"`
+    Description  string   `json:"description,omitempty"`
+    Headings     []string `json:"headings,omitempty"`
+    Images       []string `json:"images,omitempty"`
+}
+
+// 按 URL 保存的条件请求缓存，可在多次爬取之间持久化
+type pageCache struct {
+    mu      sync.Mutex
+    entries map[string]cacheEntry
 }
 
 func main() {
@@ -37,10 +139,36 @@ func main() {
     startURL := flag.String("url", "https://go.dev/", "起始 URL")
     maxDepth := flag.Int("depth", 2, "最大爬取深度")
     maxURLs := flag.Int("max", 5, "最大爬取 URL 数量")
+    maxPages := flag.Int("max-pages", 0, "实际爬取并记录的页面数 (0 表示与 -max 相同)")
+    budget := flag.Float64("crawl-budget", 0, "加权爬取预算，每个 HTML 页面计 1 (0 表示不限制)")
+    assetCost := flag.Float64("asset-cost", 0.1, "每个非 HTML 资源 (图片、样式、脚本等) 消耗的预算")
     sameHost := flag.Bool("same-host", true, "仅爬取相同主机的 URL")
     timeout := flag.Duration("timeout", 10*time.Second, "HTTP 请求超时")
+    retries := flag.Int("retries", 0, "网络错误或 5xx 状态码时的重试次数")
+    retryBackoff := flag.Duration("retry-backoff", 500*time.Millisecond, "首次重试前的等待时间，之后每次翻倍")
     concurrent := flag.Int("concurrent", 5, "并发爬取数量")
     outputFile := flag.String("output", "", "输出结果到文件")
+    outputEvery := flag.Int("output-every", 0, "每爬取 N 个页面将当前结果写入 -output 文件 (0 表示只在结束时写入)")
+    sitemapFile := flag.String("sitemap", "", "将成功爬取的同主机页面写入 sitemap.xml")
+    visitedOut := flag.String("visited-out", "", "将发现的全部 URL (包括未爬取的) 写入文件")
+    delay := flag.Duration("delay", 0, "同一主机请求之间的最小间隔")
+    jitter := flag.Float64("jitter", 0.3, "在最小间隔之上随机增加的比例 (0-1)")
+    allowHosts := flag.String("allow-hosts", "", "仅爬取这些主机及其子域名 (逗号分隔)")
+    denyHosts := flag.String("deny-hosts", "", "不爬取这些主机及其子域名 (逗号分隔)")
+    include := flag.String("include", "", "仅将匹配该正则的链接加入队列 (起始 URL 不受限制)")
+    exclude := flag.String("exclude", "", "不将匹配该正则的链接加入队列")
+    formURL := flag.String("form-url", "", "提交该表单并将响应作为种子页面")
+    formMethod := flag.String("form-method", "GET", "表单提交方式 (GET 或 POST)")
+    var formFields stringList
+    flag.Var(&formFields, "form-field", "表单字段 key=value (可重复指定)")
+    userAgent := flag.String("user-agent", defaultUserAgent, "请求使用的 User-Agent")
+    var headers stringList
+    flag.Var(&headers, "header", "附加的请求头 key:value (可重复指定)")
+    maxLinksPerPage := flag.Int("max-links-per-page", 0, "单个页面最多加入队列的链接数 (0 表示不限制)")
+    profile := flag.String("profile", "", "礼貌爬取预设 (gentle, normal, aggressive)，显式指定的 -concurrent/-delay/-jitter/-max-links-per-page 优先")
+    ignoreRobots := flag.Bool("ignore-robots", false, "忽略 robots.txt 中的 Disallow 规则")
+    saveDir := flag.String("save-dir", "", "将成功响应的页面正文保存到该目录")
+    cacheFile := flag.String("cache", "", "条件请求缓存文件，记录 Last-Modified/ETag，重新爬取时跳过未变化的页面")
     flag.Parse()
 
     // 验证起始 URL
@@ -51,24 +179,156 @@ func main() {
 
     // 创建爬虫配置
     config := CrawlerConfig{
-        StartURL:   *startURL,
-        MaxDepth:   *maxDepth,
-        MaxURLs:    *maxURLs,
-        SameHost:   *sameHost,
-        Timeout:    *timeout,
-        Concurrent: *concurrent,
+        StartURL:        *startURL,
+        MaxDepth:        *maxDepth,
+        MaxURLs:         *maxURLs,
+        MaxPages:        *maxPages,
+        SameHost:        *sameHost,
+        Timeout:         *timeout,
+        Concurrent:      *concurrent,
+        MaxLinksPerPage: *maxLinksPerPage,
+        Delay:           *delay,
+        Jitter:          *jitter,
+        AllowHosts:      parseHostList(*allowHosts),
+        DenyHosts:       parseHostList(*denyHosts),
+        FormURL:         *formURL,
+        FormMethod:      strings.ToUpper(*formMethod),
+        Retries:         *retries,
+        RetryBackoff:    *retryBackoff,
+        Budget:          *budget,
+        AssetCost:       *assetCost,
+        SaveDir:         *saveDir,
+        UserAgent:       *userAgent,
+        OutputFile:      *outputFile,
+        OutputEvery:     *outputEvery,
+    }
+
+    if *profile != "" {
+        explicit := make(map[string]bool)
+        flag.Visit(func(f *flag.Flag) {
+            explicit[f.Name] = true
+        })
+        if err := applyProfile(&config, *profile, explicit); err != nil {
+            fmt.Println(err)
+            os.Exit(1)
+        }
+    }
+
+    if *include != "" {
+        re, err := regexp.Compile(*include)
+        if err != nil {
+            fmt.Printf("无效的 -include 正则: %v\n", err)
+            os.Exit(1)
+        }
+        config.Include = re
+    }
+    if *exclude != "" {
+        re, err := regexp.Compile(*exclude)
+        if err != nil {
+            fmt.Printf("无效的 -exclude 正则: %v\n", err)
+            os.Exit(1)
+        }
+        config.Exclude = re
+    }
+
+    if config.OutputEvery > 0 && config.OutputFile == "" {
+        fmt.Println("-output-every 需要同时指定 -output")
+        os.Exit(1)
+    }
+
+    if config.Jitter < 0 || config.Jitter > 1 {
+        fmt.Println("抖动比例必须在 0 到 1 之间")
+        os.Exit(1)
+    }
+
+    if !*ignoreRobots {
+        config.Robots = newRobotsCache()
+    }
+
+    if config.SaveDir != "" {
+        if err := os.MkdirAll(config.SaveDir, 0755); err != nil {
+            fmt.Printf("创建保存目录失败: %v\n", err)
+            os.Exit(1)
+        }
+    }
+
+    if *cacheFile != "" {
+        cache, err := loadPageCache(*cacheFile)
+        if err != nil {
+            fmt.Printf("读取缓存失败: %v\n", err)
+            os.Exit(1)
+        }
+        config.Cache = cache
+    }
+
+    requestHeaders, err := parseHeaders(headers)
+    if err != nil {
+        fmt.Printf("无效的请求头: %v\n", err)
+        os.Exit(1)
+    }
+    config.Headers = requestHeaders
+
+    if config.FormURL != "" {
+        fields, err := parseFormFields(formFields)
+        if err != nil {
+            fmt.Printf("无效的表单字段: %v\n", err)
+            os.Exit(1)
+        }
+        config.FormFields = fields
     }
 
     // 开始爬取
-    fmt.Printf("开始从 %s 爬取网页 (最大深度: %d, 最大 URL 数: %d)\n",
-        config.StartURL, config.MaxDepth, config.MaxURLs)
+    fmt.Printf("开始从 %s 爬取网页 (最大深度: %d, 最大 URL 数: %d, 最大页面数: %d)\n",
+        config.StartURL, config.MaxDepth, config.MaxURLs, config.pageLimit())
+
+    // 收到 Ctrl+C 后停止爬取并保留已有结果，再次按下时直接退出
+    ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+    defer stop()
+    context.AfterFunc(ctx, func() {
+        stop()
+        fmt.Printf("\n收到中断信号，停止爬取新的页面，最多等待 %v 完成进行中的请求...\n", shutdownGrace)
+    })
 
     startTime := time.Now()
-    results := crawl(config)
+    results, discovered := crawl(ctx, config)
     elapsed := time.Since(startTime)
 
     // 显示结果
-    fmt.Printf("\n爬取完成! 共爬取 %d 个页面, 耗时: %v\n", len(results), elapsed)
+    fmt.Printf("\n爬取完成! 共爬取 %d 个页面, 发现 %d 个 URL, 耗时: %v\n",
+        len(results), len(discovered), elapsed)
+    if ctx.Err() != nil {
+        fmt.Println("爬取被中断，以下为部分结果")
+    }
+    stats := summarize(results)
+    fmt.Printf("共下载 %d 字节 (%.2f KB), 平均每页 %.0f 字节, 出错页面 %d 个\n",
+        stats.Bytes, float64(stats.Bytes)/1024, stats.averageBytes(), stats.Errors)
+
+    // 保存条件请求缓存，供下次爬取使用
+    if config.Cache != nil {
+        if err := config.Cache.save(*cacheFile); err != nil {
+            fmt.Printf("保存缓存失败: %v\n", err)
+        } else {
+            fmt.Printf("缓存已保存到: %s (未变化页面 %d 个)\n", *cacheFile, countUnchanged(results))
+        }
+    }
+
+    // 写入站点地图
+    if *sitemapFile != "" {
+        if err := writeSitemap(*sitemapFile, results, config.baseHost()); err != nil {
+            fmt.Printf("写入站点地图失败: %v\n", err)
+        } else {
+            fmt.Printf("站点地图已保存到: %s\n", *sitemapFile)
+        }
+    }
+
+    // 写入发现的全部 URL
+    if *visitedOut != "" {
+        if err := writeVisited(*visitedOut, discovered); err != nil {
+            fmt.Printf("写入发现的 URL 失败: %v\n", err)
+        } else {
+            fmt.Printf("发现的 URL 已保存到: %s\n", *visitedOut)
+        }
+    }
 
     // 如果指定了输出文件，将结果写入文件
     if *outputFile != "" {
@@ -83,26 +343,120 @@ func main() {
     }
 }
 
-// 爬取网页
-func crawl(config CrawlerConfig) []PageData {
-    startURL, _ := url.Parse(config.StartURL)
-    baseHost := startURL.Host
+// 爬取的站点主机，用于同主机过滤和站点地图，提交表单时为表单地址的主机
+func (c CrawlerConfig) baseHost() string {
+    base := c.StartURL
+    if c.FormURL != "" {
+        base = c.FormURL
+    }
+    u, err := url.Parse(base)
+    if err != nil {
+        return ""
+    }
+    return u.Host
+}
 
-    // 存储已访问的 URL
-    visited := make(map[string]bool)
-    visitedMutex := sync.Mutex{}
+// 爬取网页，返回爬取结果和发现的全部 URL
+func crawl(ctx context.Context, config CrawlerConfig) ([]PageData, []string) {
+    startURL, _ := url.Parse(config.StartURL)
+    baseHost := config.baseHost()
+    maxPages := config.pageLimit()
 
     // 存储结果
     var results []PageData
     resultsMutex := sync.Mutex{}
 
     // 创建爬取队列和等待组
-    queue := make(chan PageData, config.MaxURLs)
+    front := newFrontier(config.MaxURLs)
     var wg sync.WaitGroup
 
-    // 添加起始 URL
-    queue <- PageData{URL: config.StartURL, Depth: 0}
-    visited[config.StartURL] = true
+    // 同一主机的请求之间保持间隔，不同主机之间互不影响
+    var limiter *hostLimiter
+    if config.Delay > 0 {
+        limiter = newHostLimiter(config.Delay, config.Jitter)
+    }
+
+    // ctx 取消后不再爬取新页面，进行中的请求在 shutdownGrace 后才被取消
+    fetchCtx, cancelFetch := context.WithCancel(context.WithoutCancel(ctx))
+    defer cancelFetch()
+    stopGrace := context.AfterFunc(ctx, func() {
+        time.AfterFunc(shutdownGrace, cancelFetch)
+    })
+    defer stopGrace()
+
+    if config.FormURL != "" {
+        // 提交表单，将响应作为种子页面
+        seed := submitForm(config)
+        results = append(results, seed)
+        if seed.Error != nil {
+            fmt.Printf("提交表单失败: %v\n", seed.Error)
+            return results, front.discoveredURLs()
+        }
+        if n, _ := enqueueLinks(ctx, front, seed, seed.Links, config, baseHost); n == 0 {
+            fmt.Println("表单响应中没有可爬取的链接")
+            return results, front.discoveredURLs()
+        }
+    } else {
+        // 起始 URL 同样遵守 robots.txt
+        if !config.Robots.allowed(ctx, startURL, config) {
+            fmt.Printf("robots.txt 禁止爬取起始 URL: %s\n", config.StartURL)
+            return results, []string{config.StartURL}
+        }
+
+        // 添加起始 URL
+        front.push(PageData{URL: config.StartURL, Depth: 0})
+    }
+
+    // 处理队列中的一个页面，并将发现的链接加入队列
+    started := len(results) // 已开始爬取的页面数，包括表单种子页面
+    spent := 0.0            // 已消耗的加权预算
+    visit := func(page PageData) {
+        // 检查深度限制，中断后只清空队列
+        if page.Depth > config.MaxDepth || ctx.Err() != nil {
+            return
+        }
+
+        // 达到页面上限或预算不足后只清空队列，不再爬取
+        cost := config.urlCost(page.URL)
+        resultsMutex.Lock()
+        if started >= maxPages || (config.Budget > 0 && spent+cost > config.Budget) {
+            resultsMutex.Unlock()
+            return
+        }
+        started++
+        spent += cost
+        resultsMutex.Unlock()
+
+        // 爬取页面
+        pageData := fetchPage(fetchCtx, page.URL, config, limiter)
+        pageData.Depth = page.Depth
+        pageData.Parent = page.Parent
+
+        // 保存结果，按实际的内容类型结算预算
+        resultsMutex.Lock()
+        spent += config.contentCost(pageData.ContentType, cost) - cost
+        results = append(results, pageData)
+        fmt.Printf("\r已爬取 %d/%d 个页面", len(results), maxPages)
+
+        // 持有锁写入快照，避免并发写入同一文件
+        if config.OutputEvery > 0 && len(results)%config.OutputEvery == 0 {
+            if err := writeResults(config.OutputFile, results); err != nil {
+                fmt.Printf("\n写入结果快照失败: %v\n", err)
+            }
+        }
+        resultsMutex.Unlock()
+
+        // 如果有错误或已中断，不继续处理链接
+        if pageData.Error != nil || ctx.Err() != nil {
+            return
+        }
+
+        // 处理页面中的链接
+        if _, truncated := enqueueLinks(ctx, front, pageData, pageData.Links, config, baseHost); truncated {
+            fmt.Printf("\n页面 %s 的链接超过上限 %d 个，其余链接已忽略\n",
+                page.URL, config.MaxLinksPerPage)
+        }
+    }
 
     // 启动工作协程
     for i := 0; i < config.Concurrent; i++ {
@@ -110,139 +464,859 @@ func crawl(config CrawlerConfig) []PageData {
         go func() {
             defer wg.Done()
 
-            for {
-                // 检查队列
+            queue, assets := front.queue, front.assets
+            for queue != nil || assets != nil {
                 var page PageData
-                var more bool
+                var ok bool
 
+                // 优先处理 HTML 页面，没有时再处理资源
                 select {
-                case page, more = <-queue:
-                    if !more {
-                        return
+                case page, ok = <-queue:
+                    if !ok {
+                        queue = nil
+                        continue
                     }
                 default:
-                    // 队列为空，检查是否还有工作在进行
-                    visitedMutex.Lock()
-                    count := len(visited)
-                    visitedMutex.Unlock()
-
-                    resultsMutex.Lock()
-                    resCount := len(results)
-                    resultsMutex.Unlock()
-
-                    if count >= config.MaxURLs || resCount >= config.MaxURLs {
-                        return
-                    }
-                    
-                    // 等待队列中的数据
-                    page, more = <-queue
-                    if !more {
-                        return
+                    select {
+                    case page, ok = <-queue:
+                        if !ok {
+                            queue = nil
+                            continue
+                        }
+                    case page, ok = <-assets:
+                        if !ok {
+                            assets = nil
+                            continue
+                        }
                     }
                 }
 
-                // 检查深度限制
-                if page.Depth > config.MaxDepth {
-                    continue
-                }
+                visit(page)
+                front.done()
+            }
+        }()
+    }
 
-                // 爬取页面
-                pageData := fetchPage(page.URL, config.Timeout)
-                pageData.Depth = page.Depth
+    // 所有已入队的 URL 处理完毕后关闭队列，工作协程随之退出
+    go func() {
+        front.pending.Wait()
+        close(front.queue)
+        close(front.assets)
+    }()
 
-                // 保存结果
-                resultsMutex.Lock()
-                if len(results) < config.MaxURLs {
-                    results = append(results, pageData)
-                    fmt.Printf("\r已爬取 %d/%d 个页面", len(results), config.MaxURLs)
-                }
-                resultsMutex.Unlock()
+    // 等待所有工作完成
+    wg.Wait()
 
-                // 如果达到最大 URL 数，关闭队列
-                resultsMutex.Lock()
-                if len(results) >= config.MaxURLs {
-                    resultsMutex.Unlock()
-                    return
-                }
-                resultsMutex.Unlock()
+    if config.Budget > 0 {
+        fmt.Printf("\n已消耗爬取预算 %.2f/%.2f\n", spent, config.Budget)
+    }
 
-                // 如果有错误，不继续处理链接
-                if pageData.Error != nil {
-                    continue
-                }
+    return results, front.discoveredURLs()
+}
 
-                // 处理页面中的链接
-                for _, link := range pageData.Links {
-                    linkURL, err := url.Parse(link)
-                    if err != nil {
-                        continue
-                    }
+// 实际爬取并记录的页面数上限
+func (c CrawlerConfig) pageLimit() int {
+    if c.MaxPages > 0 {
+        return c.MaxPages
+    }
+    return c.MaxURLs
+}
 
-                    // 处理相对 URL
-                    if !linkURL.IsAbs() {
-                        baseURL, _ := url.Parse(page.URL)
-                        linkURL = baseURL.ResolveReference(linkURL)
-                    }
+// 按扩展名识别为非 HTML 资源的文件类型
+var assetExtensions = map[string]bool{
+    ".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".svg": true, ".webp": true, ".ico": true,
+    ".css": true, ".js": true, ".pdf": true, ".zip": true, ".mp4": true, ".mp3": true,
+    ".woff": true, ".woff2": true, ".ttf": true,
+}
 
-                    absLink := linkURL.String()
+// 根据 URL 的扩展名判断是否是资源文件
+func isAssetURL(pageURL string) bool {
+    u, err := url.Parse(pageURL)
+    if err != nil {
+        return false
+    }
+    return assetExtensions[strings.ToLower(path.Ext(u.Path))]
+}
 
-                    // 跳过非 HTTP/HTTPS 链接
-                    if !strings.HasPrefix(absLink, "http") {
-                        continue
-                    }
+// 爬取前按 URL 估计的预算消耗
+func (c CrawlerConfig) urlCost(pageURL string) float64 {
+    if isAssetURL(pageURL) {
+        return c.AssetCost
+    }
+    return 1
+}
 
-                    // 检查是否应该仅爬取相同主机
-                    if config.SameHost && linkURL.Host != baseHost {
-                        continue
-                    }
+// 按响应的内容类型计算预算消耗，没有内容类型时沿用估计值
+func (c CrawlerConfig) contentCost(contentType string, estimated float64) float64 {
+    if contentType == "" {
+        return estimated
+    }
+    if isHTMLContent(contentType) {
+        return 1
+    }
+    return c.AssetCost
+}
 
-                    // 检查是否已访问
-                    visitedMutex.Lock()
-                    if !visited[absLink] && len(visited) < config.MaxURLs {
-                        visited[absLink] = true
-                        queue <- PageData{URL: absLink, Depth: page.Depth + 1}
-                    }
-                    visitedMutex.Unlock()
-                }
+// 判断内容类型是否是 HTML 页面
+func isHTMLContent(contentType string) bool {
+    mediaType, _, _ := strings.Cut(strings.ToLower(contentType), ";")
+    mediaType = strings.TrimSpace(mediaType)
+    return mediaType == "text/html" || mediaType == "application/xhtml+xml"
+}
+
+// 爬取队列及已访问 URL 记录
+type frontier struct {
+    mu         sync.Mutex
+    visited    map[string]bool
+    queue      chan PageData // HTML 页面
+    assets     chan PageData // 图片、样式等资源，优先级低于 HTML 页面
+    limit      int
+    seen       map[string]bool // 发现过的全部 URL，不受 limit 限制
+    discovered []string        // 按发现顺序记录的 URL
+    pending    sync.WaitGroup  // 已入队但尚未处理完的 URL
+}
+
+// 创建最多容纳 limit 个 URL 的爬取队列
+func newFrontier(limit int) *frontier {
+    return &frontier{
+        visited: make(map[string]bool),
+        queue:   make(chan PageData, limit),
+        assets:  make(chan PageData, limit),
+        limit:   limit,
+        seen:    make(map[string]bool),
+    }
+}
+
+// 将未访问过的 URL 加入队列，已访问或达到上限时返回 false
+func (f *frontier) push(page PageData) bool {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+
+    if !f.seen[page.URL] {
+        f.seen[page.URL] = true
+        f.discovered = append(f.discovered, page.URL)
+    }
+
+    if f.visited[page.URL] || len(f.visited) >= f.limit {
+        return false
+    }
+    f.visited[page.URL] = true
+    f.pending.Add(1)
+    if isAssetURL(page.URL) {
+        f.assets <- page
+    } else {
+        f.queue <- page
+    }
+    return true
+}
+
+// 标记一个已入队的 URL 处理完毕
+func (f *frontier) done() {
+    f.pending.Done()
+}
+
+// 按发现顺序返回发现过的全部 URL
+func (f *frontier) discoveredURLs() []string {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    return append([]string(nil), f.discovered...)
+}
+
+// 将页面中发现的链接加入队列，返回加入的数量以及是否因单页上限被截断
+func enqueueLinks(ctx context.Context, front *frontier, page PageData, links []string, config CrawlerConfig, baseHost string) (int, bool) {
+    // 超出深度限制的链接不会被爬取，不占用队列的 URL 上限
+    if page.Depth+1 > config.MaxDepth {
+        return 0, false
+    }
+
+    baseURL, err := page.linkBase()
+    if err != nil {
+        return 0, false
+    }
+
+    enqueued := 0
+    for _, link := range links {
+        linkURL, err := resolveURL(baseURL, link)
+        if err != nil {
+            continue
+        }
+
+        absLink := linkURL.String()
+
+        // 跳过非 HTTP/HTTPS 链接
+        if !strings.HasPrefix(absLink, "http") {
+            continue
+        }
+
+        // 检查是否应该仅爬取相同主机
+        if config.SameHost && linkURL.Host != baseHost {
+            continue
+        }
+
+        // 检查 URL 包含/排除规则
+        if !urlAllowed(absLink, config) {
+            continue
+        }
+
+        // 检查主机允许/禁止列表
+        if !hostAllowed(linkURL.Hostname(), config) {
+            continue
+        }
+
+        // 检查 robots.txt 规则
+        if !config.Robots.allowed(ctx, linkURL, config) {
+            continue
+        }
+
+        // 检查单页链接上限
+        if config.MaxLinksPerPage > 0 && enqueued >= config.MaxLinksPerPage {
+            return enqueued, true
+        }
+
+        // 检查是否已访问
+        if front.push(PageData{URL: absLink, Parent: page.URL, Depth: page.Depth + 1}) {
+            enqueued++
+        }
+    }
+
+    return enqueued, false
+}
+
+// 应用礼貌爬取预设，explicit 中记录的命令行参数保持用户指定的值
+func applyProfile(config *CrawlerConfig, name string, explicit map[string]bool) error {
+    profile, ok := crawlProfiles[name]
+    if !ok {
+        names := make([]string, 0, len(crawlProfiles))
+        for n := range crawlProfiles {
+            names = append(names, n)
+        }
+        sort.Strings(names)
+        return fmt.Errorf("未知的预设: %s (可用: %s)", name, strings.Join(names, ", "))
+    }
+
+    if !explicit["concurrent"] {
+        config.Concurrent = profile.Concurrent
+    }
+    if !explicit["delay"] {
+        config.Delay = profile.Delay
+    }
+    if !explicit["jitter"] {
+        config.Jitter = profile.Jitter
+    }
+    if !explicit["max-links-per-page"] {
+        config.MaxLinksPerPage = profile.MaxLinksPerPage
+    }
+    return nil
+}
+
+// 单个主机 robots.txt 中适用于所有爬虫 (User-agent: *) 的禁止路径
+type robotsRules struct {
+    disallow []string
+}
+
+// 按主机缓存的 robots.txt 规则，每个主机只请求一次
+type robotsCache struct {
+    mu      sync.Mutex
+    entries map[string]*robotsEntry
+}
+
+// 单个主机的 robots.txt，获取期间同一主机的其他检查等待 ready 关闭
+type robotsEntry struct {
+    ready chan struct{}
+    rules *robotsRules // 获取被取消时为 nil
+}
+
+// 创建 robots.txt 缓存
+func newRobotsCache() *robotsCache {
+    return &robotsCache{
+        entries: make(map[string]*robotsEntry),
+    }
+}
+
+// 判断 URL 是否允许爬取，首次遇到主机时获取其 robots.txt，缓存为 nil 时总是允许
+func (c *robotsCache) allowed(ctx context.Context, u *url.URL, config CrawlerConfig) bool {
+    if c == nil {
+        return true
+    }
+
+    // 在锁外请求 robots.txt，不阻塞其他主机的检查
+    c.mu.Lock()
+    entry, ok := c.entries[u.Host]
+    if !ok {
+        entry = &robotsEntry{ready: make(chan struct{})}
+        c.entries[u.Host] = entry
+    }
+    c.mu.Unlock()
+
+    if ok {
+        select {
+        case <-entry.ready:
+        case <-ctx.Done():
+            return false
+        }
+    } else {
+        entry.rules = fetchRobots(ctx, u.Scheme+"://"+u.Host+"/robots.txt", config)
+        if entry.rules == nil {
+            // 获取被取消，之后再遇到该主机时重新获取
+            c.mu.Lock()
+            delete(c.entries, u.Host)
+            c.mu.Unlock()
+        }
+        close(entry.ready)
+    }
+
+    // 爬取已被取消，不再加入新的链接
+    if entry.rules == nil {
+        return false
+    }
+    return entry.rules.allowed(u.RequestURI())
+}
+
+// 获取并解析 robots.txt，请求失败或不存在时不限制，ctx 取消时返回 nil
+func fetchRobots(ctx context.Context, robotsURL string, config CrawlerConfig) *robotsRules {
+    req, err := http.NewRequestWithContext(ctx, http.MethodGet, robotsURL, nil)
+    if err != nil {
+        return &robotsRules{}
+    }
+    config.setHeaders(req)
+
+    resp, err := config.httpClient().Do(req)
+    if err != nil {
+        if ctx.Err() != nil {
+            return nil
+        }
+        return &robotsRules{}
+    }
+    defer resp.Body.Close()
+
+    if resp.StatusCode != http.StatusOK {
+        return &robotsRules{}
+    }
+    return parseRobots(resp.Body)
+}
+
+// 解析 robots.txt 中 User-agent: * 分组的 Disallow 规则
+func parseRobots(r io.Reader) *robotsRules {
+    rules := &robotsRules{}
+    applies := false     // 当前分组是否适用于所有爬虫
+    inAgentList := false // 是否正在读取分组开头的 User-agent 行
+
+    scanner := bufio.NewScanner(r)
+    for scanner.Scan() {
+        line := scanner.Text()
+        if i := strings.Index(line, "#"); i >= 0 {
+            line = line[:i]
+        }
+        key, value, ok := strings.Cut(line, ":")
+        if !ok {
+            continue
+        }
+        key = strings.ToLower(strings.TrimSpace(key))
+        value = strings.TrimSpace(value)
+
+        switch key {
+        case "user-agent":
+            // 连续的 User-agent 行属于同一分组
+            if !inAgentList {
+                applies = false
             }
-        }()
+            inAgentList = true
+            if value == "*" {
+                applies = true
+            }
+        case "disallow":
+            inAgentList = false
+            // 空的 Disallow 表示不限制
+            if applies && value != "" {
+                rules.disallow = append(rules.disallow, value)
+            }
+        default:
+            inAgentList = false
+        }
     }
 
-    // 等待队列变空
-    go func() {
-        wg.Wait()
-        close(queue)
-    }()
+    return rules
+}
 
-    // 等待所有工作完成
-    wg.Wait()
+// 判断路径(含查询参数)是否不在任何禁止路径之下
+func (r *robotsRules) allowed(path string) bool {
+    if path == "" {
+        path = "/"
+    }
+    for _, prefix := range r.disallow {
+        if strings.HasPrefix(path, prefix) {
+            return false
+        }
+    }
+    return true
+}
 
-    return results
+// 页面是否爬取成功：没有错误且响应为 2xx 或 304，4xx 等页面仍会解析链接但不算成功
+func (p PageData) fetched() bool {
+    if p.Error != nil {
+        return false
+    }
+    return p.StatusCode == http.StatusNotModified || (p.StatusCode >= 200 && p.StatusCode < 300)
 }
 
-// 获取页面数据
-func fetchPage(url string, timeout time.Duration) PageData {
-    client := &http.Client{
-        Timeout: timeout,
+// 解析页面中相对地址的基准，声明了 <base href> 时使用该地址，否则使用页面 URL
+func (p PageData) linkBase() (*url.URL, error) {
+    if p.BaseHref != "" {
+        return url.Parse(p.BaseHref)
     }
+    return url.Parse(p.URL)
+}
 
-    resp, err := client.Get(url)
+// 将页面中的地址解析为绝对 URL
+func resolveURL(baseURL *url.URL, ref string) (*url.URL, error) {
+    refURL, err := url.Parse(ref)
     if err != nil {
-        return PageData{URL: url, Error: err}
+        return nil, err
+    }
+
+    // 处理相对 URL
+    if !refURL.IsAbs() {
+        refURL = baseURL.ResolveReference(refURL)
+    }
+    return refURL, nil
+}
+
+// 解析逗号分隔的主机列表
+func parseHostList(list string) []string {
+    var hosts []string
+    for _, host := range strings.Split(list, ",") {
+        host = strings.ToLower(strings.TrimSpace(host))
+        if host != "" {
+            hosts = append(hosts, host)
+        }
+    }
+    return hosts
+}
+
+// 判断主机是否是列表中的主机或其子域名
+func hostMatches(host string, hosts []string) bool {
+    host = strings.ToLower(host)
+    for _, h := range hosts {
+        if host == h || strings.HasSuffix(host, "."+h) {
+            return true
+        }
+    }
+    return false
+}
+
+// 根据允许/禁止列表判断主机是否可以爬取
+func hostAllowed(host string, config CrawlerConfig) bool {
+    if hostMatches(host, config.DenyHosts) {
+        return false
+    }
+    if len(config.AllowHosts) > 0 && !hostMatches(host, config.AllowHosts) {
+        return false
+    }
+    return true
+}
+
+// 根据包含/排除正则判断链接是否可以加入队列
+func urlAllowed(link string, config CrawlerConfig) bool {
+    if config.Exclude != nil && config.Exclude.MatchString(link) {
+        return false
+    }
+    if config.Include != nil && !config.Include.MatchString(link) {
+        return false
+    }
+    return true
+}
+
+// 在基础间隔之上随机增加 0 到 jitter 比例的抖动，结果不小于基础间隔
+func jitteredDelay(base time.Duration, jitter float64) time.Duration {
+    if jitter <= 0 {
+        return base
+    }
+    factor := 1 + jitter*rand.Float64()
+    return time.Duration(float64(base) * factor)
+}
+
+// 读取缓存文件，文件不存在时返回空缓存
+func loadPageCache(filename string) (*pageCache, error) {
+    cache := &pageCache{entries: make(map[string]cacheEntry)}
+
+    data, err := os.ReadFile(filename)
+    if errors.Is(err, os.ErrNotExist) {
+        return cache, nil
+    }
+    if err != nil {
+        return nil, err
+    }
+    if err := json.Unmarshal(data, &cache.entries); err != nil {
+        return nil, fmt.Errorf("解析缓存文件 %s 失败: %v", filename, err)
+    }
+    return cache, nil
+}
+
+// 将缓存写入文件
+func (c *pageCache) save(filename string) error {
+    c.mu.Lock()
+    data, err := json.MarshalIndent(c.entries, "", "  ")
+    c.mu.Unlock()
+    if err != nil {
+        return err
+    }
+    return os.WriteFile(filename, data, 0644)
+}
+
+// 查找 URL 的缓存记录，缓存为 nil 时总是返回 false
+func (c *pageCache) get(pageURL string) (cacheEntry, bool) {
+    if c == nil {
+        return cacheEntry{}, false
+    }
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    entry, ok := c.entries[pageURL]
+    return entry, ok
+}
+
+// 记录页面的校验信息和解析结果，响应没有校验信息时不记录
+func (c *pageCache) put(pageURL string, header http.Header, page PageData) {
+    if c == nil {
+        return
+    }
+    entry := cacheEntry{
+        LastModified: header.Get("Last-Modified"),
+        ETag:         header.Get("ETag"),
+        Title:        page.Title,
+        Links:        page.Links,
+        BaseHref:     page.BaseHref,
+        Description:  page.Description,
+        Headings:     page.Headings,
+        Images:       page.Images,
+    }
+    if entry.LastModified == "" && entry.ETag == "" {
+        return
+    }
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    c.entries[pageURL] = entry
+}
+
+// 统计未变化的页面数量
+func countUnchanged(results []PageData) int {
+    count := 0
+    for _, page := range results {
+        if page.Unchanged {
+            count++
+        }
+    }
+    return count
+}
+
+// 按主机限制请求频率，记录每个主机下一次允许请求的时间
+type hostLimiter struct {
+    mu     sync.Mutex
+    delay  time.Duration
+    jitter float64
+    next   map[string]time.Time
+}
+
+// 创建同一主机请求间隔至少为 delay 的限速器
+func newHostLimiter(delay time.Duration, jitter float64) *hostLimiter {
+    return &hostLimiter{
+        delay:  delay,
+        jitter: jitter,
+        next:   make(map[string]time.Time),
+    }
+}
+
+// 等待直到可以向该主机发出请求，限速器为 nil 时不等待
+func (l *hostLimiter) wait(host string) {
+    if l == nil {
+        return
+    }
+
+    // 在锁内预约请求时间，锁外等待，其他主机的请求不受影响
+    l.mu.Lock()
+    now := time.Now()
+    slot := l.next[host]
+    if slot.Before(now) {
+        slot = now
+    }
+    l.next[host] = slot.Add(l.interval())
+    l.mu.Unlock()
+
+    time.Sleep(slot.Sub(now))
+}
+
+// 两次请求之间的间隔，抖动只在最小间隔之上增加
+func (l *hostLimiter) interval() time.Duration {
+    return jitteredDelay(l.delay, l.jitter)
+}
+
+// 获取页面数据，有缓存记录时发送条件请求，网络错误和 5xx 按配置重试
+func fetchPage(parent context.Context, url string, config CrawlerConfig, limiter *hostLimiter) PageData {
+    client := config.httpClient()
+
+    // 所有重试共用一个截止时间，避免异常的主机拖住爬取
+    ctx, cancel := context.WithTimeout(parent, config.Timeout*time.Duration(config.Retries+1))
+    defer cancel()
+
+    req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+    if err != nil {
+        return PageData{URL: url, Error: err, Attempts: 1}
+    }
+    config.setHeaders(req)
+    entry, cached := config.Cache.get(url)
+    if cached {
+        if entry.LastModified != "" {
+            req.Header.Set("If-Modified-Since", entry.LastModified)
+        }
+        if entry.ETag != "" {
+            req.Header.Set("If-None-Match", entry.ETag)
+        }
+    }
+
+    var resp *http.Response
+    attempts := 0
+    backoff := config.RetryBackoff
+    for {
+        attempts++
+        limiter.wait(req.URL.Host)
+        resp, err = client.Do(req)
+
+        // 4xx 等其他响应不重试
+        retryable := err != nil || resp.StatusCode >= http.StatusInternalServerError
+        if !retryable || attempts > config.Retries {
+            break
+        }
+
+        // 指数退避，截止时间到达时使用最后一次的结果
+        timer := time.NewTimer(backoff)
+        select {
+        case <-timer.C:
+        case <-ctx.Done():
+            timer.Stop()
+        }
+        if ctx.Err() != nil {
+            break
+        }
+        if resp != nil {
+            resp.Body.Close()
+        }
+        backoff *= 2
+    }
+    if err != nil {
+        return PageData{URL: url, Error: err, Attempts: attempts}
     }
     defer resp.Body.Close()
 
-    // 解析 HTML
-    doc, err := html.Parse(resp.Body)
+    if resp.StatusCode >= http.StatusInternalServerError {
+        return PageData{URL: url, Error: fmt.Errorf("服务器返回状态码 %d", resp.StatusCode), Attempts: attempts,
+            StatusCode: resp.StatusCode}
+    }
+
+    // 页面未变化，沿用上次的解析结果
+    if cached && resp.StatusCode == http.StatusNotModified {
+        return PageData{URL: url, Title: entry.Title, Links: entry.Links, BaseHref: entry.BaseHref,
+            Description: entry.Description, Headings: entry.Headings, Images: entry.Images, Unchanged: true,
+            LastModified: entry.LastModified, Attempts: attempts, StatusCode: resp.StatusCode}
+    }
+
+    // 统计实际读取的字节数，需要保存正文时先完整读取
+    counter := &countingReader{r: resp.Body}
+    var body io.Reader = counter
+    savedPath := ""
+    if config.SaveDir != "" && resp.StatusCode == http.StatusOK {
+        data, err := io.ReadAll(counter)
+        if err != nil {
+            return PageData{URL: url, Error: err, Attempts: attempts, Bytes: counter.n, StatusCode: resp.StatusCode}
+        }
+        savedPath, err = savePageBody(config.SaveDir, url, data)
+        if err != nil {
+            fmt.Printf("保存页面 %s 失败: %v\n", url, err)
+        }
+        body = bytes.NewReader(data)
+    }
+
+    // 资源文件不解析链接
+    contentType := resp.Header.Get("Content-Type")
+    if contentType != "" && !isHTMLContent(contentType) {
+        io.Copy(io.Discard, body)
+        return PageData{URL: url, Attempts: attempts, ContentType: contentType, SavedPath: savedPath, Bytes: counter.n,
+            StatusCode: resp.StatusCode}
+    }
+
+    pageData := parseHTML(url, body)
+    pageData.LastModified = resp.Header.Get("Last-Modified")
+    pageData.Attempts = attempts
+    pageData.ContentType = contentType
+    pageData.SavedPath = savedPath
+    pageData.Bytes = counter.n
+    pageData.StatusCode = resp.StatusCode
+    if pageData.Error == nil {
+        config.Cache.put(url, resp.Header, pageData)
+    }
+    return pageData
+}
+
+// 记录读取字节数的 Reader
+type countingReader struct {
+    r io.Reader
+    n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+    n, err := c.r.Read(p)
+    c.n += int64(n)
+    return n, err
+}
+
+// 清理文件名，移除不合法字符
+func sanitizeFilename(filename string) string {
+    // 替换不允许作为文件名的字符
+    illegal := []string{"/", "\\", ":", "*", "?", "\"", "<", ">", "|", "&", "=", "#", "%"}
+    result := filename
+
+    for _, char := range illegal {
+        result = strings.ReplaceAll(result, char, "_")
+    }
+
+    // 限制长度
+    if len(result) > 100 {
+        result = result[:100]
+    }
+
+    return strings.TrimSpace(result)
+}
+
+// 将页面正文保存到目录中，返回文件路径
+// 文件名由清理后的 URL 加上 URL 的哈希组成，不同 URL 清理后相同时也不会互相覆盖，
+// 而同一 URL 只会被爬取一次，因此并发的协程不会写同一个文件
+func savePageBody(dir, pageURL string, body []byte) (string, error) {
+    name, ext := pageURL, ".html"
+    if u, err := url.Parse(pageURL); err == nil {
+        name = u.Host + u.RequestURI()
+        if isAssetURL(pageURL) {
+            ext = strings.ToLower(path.Ext(u.Path))
+        }
+    }
+
+    h := fnv.New32a()
+    h.Write([]byte(pageURL))
+
+    filename := filepath.Join(dir, fmt.Sprintf("%s_%08x%s", sanitizeFilename(name), h.Sum32(), ext))
+    if err := os.WriteFile(filename, body, 0644); err != nil {
+        return "", err
+    }
+    return filename, nil
+}
+
+// 解析 HTML 并提取标题和链接
+func parseHTML(pageURL string, body io.Reader) PageData {
+    doc, err := html.Parse(body)
     if err != nil {
-        return PageData{URL: url, Error: err}
+        return PageData{URL: pageURL, Error: err}
     }
 
-    // 提取标题和链接
-    pageData := PageData{URL: url}
+    pageData := PageData{URL: pageURL}
+    pageData.BaseHref = extractBaseHref(doc, pageURL)
     pageData.Title = extractTitle(doc)
     pageData.Links = extractLinks(doc)
+    extractMeta(doc, &pageData)
+
+    return pageData
+}
+
+// 解析 key=value 形式的表单字段
+func parseFormFields(fields []string) (url.Values, error) {
+    values := url.Values{}
+    for _, field := range fields {
+        key, value, ok := strings.Cut(field, "=")
+        if !ok || key == "" {
+            return nil, fmt.Errorf("字段格式应为 key=value: %s", field)
+        }
+        values.Add(key, value)
+    }
+    return values, nil
+}
+
+// 解析 key:value 形式的请求头
+func parseHeaders(headers []string) (http.Header, error) {
+    result := http.Header{}
+    for _, header := range headers {
+        key, value, ok := strings.Cut(header, ":")
+        key = strings.TrimSpace(key)
+        if !ok || key == "" {
+            return nil, fmt.Errorf("请求头格式应为 key:value: %s", header)
+        }
+        result.Add(key, strings.TrimSpace(value))
+    }
+    return result, nil
+}
+
+// 爬取页面、提交表单和获取 robots.txt 共用的客户端设置
+func (c CrawlerConfig) httpClient() *http.Client {
+    return &http.Client{
+        Timeout: c.Timeout,
+    }
+}
+
+// 设置 User-Agent 和附加的请求头
+func (c CrawlerConfig) setHeaders(req *http.Request) {
+    if c.UserAgent != "" {
+        req.Header.Set("User-Agent", c.UserAgent)
+    }
+    for key, values := range c.Headers {
+        req.Header.Del(key)
+        for _, value := range values {
+            req.Header.Add(key, value)
+        }
+    }
+}
+
+// 提交表单并解析返回的页面
+func submitForm(config CrawlerConfig) PageData {
+    client := config.httpClient()
+
+    var req *http.Request
+    var err error
+    pageURL := config.FormURL
+
+    if config.FormMethod == http.MethodPost {
+        req, err = http.NewRequest(http.MethodPost, pageURL, strings.NewReader(config.FormFields.Encode()))
+        if err != nil {
+            return PageData{URL: pageURL, Error: err}
+        }
+        req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+    } else {
+        // GET 方式将字段附加到查询参数
+        formURL, parseErr := url.Parse(pageURL)
+        if parseErr != nil {
+            return PageData{URL: pageURL, Error: parseErr}
+        }
+        query := formURL.Query()
+        for key, values := range config.FormFields {
+            for _, value := range values {
+                query.Add(key, value)
+            }
+        }
+        formURL.RawQuery = query.Encode()
+        pageURL = formURL.String()
+        req, err = http.NewRequest(http.MethodGet, pageURL, nil)
+        if err != nil {
+            return PageData{URL: pageURL, Error: err}
+        }
+    }
+    config.setHeaders(req)
+
+    resp, err := client.Do(req)
+    if err != nil {
+        return PageData{URL: pageURL, Error: err}
+    }
+    defer resp.Body.Close()
 
+    if resp.StatusCode != http.StatusOK {
+        return PageData{URL: pageURL, Error: fmt.Errorf("表单返回状态码 %d", resp.StatusCode), StatusCode: resp.StatusCode}
+    }
+
+    pageData := parseHTML(pageURL, resp.Body)
+    pageData.StatusCode = resp.StatusCode
     return pageData
 }
 
@@ -264,6 +1338,33 @@ func extractTitle(n *html.Node) string {
     return ""
 }
 
+// 提取第一个 <base href> 并解析为绝对地址，没有声明时返回空字符串
+func extractBaseHref(n *html.Node, pageURL string) string {
+    if n.Type == html.ElementNode && n.Data == "base" {
+        href := strings.TrimSpace(attrValue(n, "href"))
+        if href == "" {
+            return ""
+        }
+        pageBase, err := url.Parse(pageURL)
+        if err != nil {
+            return ""
+        }
+        baseURL, err := resolveURL(pageBase, href)
+        if err != nil {
+            return ""
+        }
+        return baseURL.String()
+    }
+
+    for c := n.FirstChild; c != nil; c = c.NextSibling {
+        if base := extractBaseHref(c, pageURL); base != "" {
+            return base
+        }
+    }
+
+    return ""
+}
+
 // 提取页面链接
 func extractLinks(n *html.Node) []string {
     var links []string
@@ -288,22 +1389,129 @@ func extractLinks(n *html.Node) []string {
     return links
 }
 
+// 提取页面描述、一级标题和图片地址
+func extractMeta(n *html.Node, pageData *PageData) {
+    baseURL, _ := pageData.linkBase()
+
+    var extractFunc func(*html.Node)
+    extractFunc = func(n *html.Node) {
+        if n.Type == html.ElementNode {
+            switch n.Data {
+            case "meta":
+                if strings.EqualFold(attrValue(n, "name"), "description") && pageData.Description == "" {
+                    pageData.Description = strings.TrimSpace(attrValue(n, "content"))
+                }
+            case "h1":
+                if text := strings.Join(strings.Fields(nodeText(n)), " "); text != "" {
+                    pageData.Headings = append(pageData.Headings, text)
+                }
+            case "img":
+                // 与链接相同的方式解析相对地址
+                if src := attrValue(n, "src"); src != "" && baseURL != nil {
+                    if imageURL, err := resolveURL(baseURL, src); err == nil {
+                        pageData.Images = append(pageData.Images, imageURL.String())
+                    }
+                }
+            }
+        }
+
+        for c := n.FirstChild; c != nil; c = c.NextSibling {
+            extractFunc(c)
+        }
+    }
+
+    extractFunc(n)
+}
+
+// 返回元素的属性值，不存在时返回空字符串
+func attrValue(n *html.Node, key string) string {
+    for _, a := range n.Attr {
+        if a.Key == key {
+            return a.Val
+        }
+    }
+    return ""
+}
+
+// 拼接节点下的全部文本
+func nodeText(n *html.Node) string {
+    if n.Type == html.TextNode {
+        return n.Data
+    }
+    var text strings.Builder
+    for c := n.FirstChild; c != nil; c = c.NextSibling {
+        text.WriteString(nodeText(c))
+    }
+    return text.String()
+}
+
 // 显示爬取结果
 func displayResults(results []PageData) {
     fmt.Println("\n爬取结果:")
     for i, page := range results {
         fmt.Printf("%d. %s\n", i+1, page.URL)
+        if page.Parent != "" {
+            fmt.Printf("   来源: %s\n", page.Parent)
+        }
         fmt.Printf("   标题: %s\n", page.Title)
+        if page.Description != "" {
+            fmt.Printf("   描述: %s\n", page.Description)
+        }
+        if len(page.Headings) > 0 {
+            fmt.Printf("   一级标题: %s\n", strings.Join(page.Headings, " | "))
+        }
         fmt.Printf("   深度: %d\n", page.Depth)
         if page.Error != nil {
             fmt.Printf("   错误: %v\n", page.Error)
         } else {
             fmt.Printf("   链接数: %d\n", len(page.Links))
+            fmt.Printf("   图片数: %d\n", len(page.Images))
+        }
+        if page.Unchanged {
+            fmt.Println("   状态: 未变化")
+        }
+        if page.Attempts > 1 {
+            fmt.Printf("   请求次数: %d\n", page.Attempts)
+        }
+        if page.Error == nil {
+            fmt.Printf("   大小: %d 字节\n", page.Bytes)
+        }
+        if page.SavedPath != "" {
+            fmt.Printf("   已保存: %s\n", page.SavedPath)
         }
         fmt.Println()
     }
 }
 
+// 爬取结果的下载量汇总
+type crawlStats struct {
+    Pages  int   // 记录的页面数
+    Errors int   // 出错的页面数
+    Bytes  int64 // 读取的响应正文总字节数
+}
+
+// 汇总下载字节数和出错页面数
+func summarize(results []PageData) crawlStats {
+    var stats crawlStats
+    for _, page := range results {
+        stats.Pages++
+        stats.Bytes += page.Bytes
+        if page.Error != nil {
+            stats.Errors++
+        }
+    }
+    return stats
+}
+
+// 成功页面的平均字节数
+func (s crawlStats) averageBytes() float64 {
+    ok := s.Pages - s.Errors
+    if ok <= 0 {
+        return 0
+    }
+    return float64(s.Bytes) / float64(ok)
+}
+
 // 将结果写入文件
 func writeResults(filename string, results []PageData) error {
     file, err := os.Create(filename)
@@ -313,7 +1521,7 @@ func writeResults(filename string, results []PageData) error {
     defer file.Close()
 
     // 写入CSV格式的标题
-    _, err = fmt.Fprintln(file, "URL,标题,深度,链接数,错误")
+    _, err = fmt.Fprintln(file, "URL,来源,标题,描述,一级标题,深度,链接数,图片,错误")
     if err != nil {
         return err
     }
@@ -322,17 +1530,86 @@ func writeResults(filename string, results []PageData) error {
     for _, page := range results {
         // 处理CSV中的特殊字符
         title := strings.ReplaceAll(page.Title, "\"", "\"\"")
+        description := strings.ReplaceAll(page.Description, "\"", "\"\"")
+        headings := strings.ReplaceAll(strings.Join(page.Headings, "; "), "\"", "\"\"")
+        images := strings.ReplaceAll(strings.Join(page.Images, " "), "\"", "\"\"")
         var errorStr string
         if page.Error != nil {
             errorStr = strings.ReplaceAll(page.Error.Error(), "\"", "\"\"")
         }
 
-        _, err := fmt.Fprintf(file, "\"%s\",\"%s\",%d,%d,\"%s\"\n",
-            page.URL, title, page.Depth, len(page.Links), errorStr)
+        _, err := fmt.Fprintf(file, "\"%s\",\"%s\",\"%s\",\"%s\",\"%s\",%d,%d,\"%s\",\"%s\"\n",
+            page.URL, page.Parent, title, description, headings, page.Depth, len(page.Links), images, errorStr)
         if err != nil {
             return err
         }
     }
 
     return nil
-}
\ No newline at end of file
+}
+
+// 站点地图中的一个页面
+type sitemapURL struct {
+    Loc     string `xml:"loc"`
+    LastMod string `xml:"lastmod,omitempty"`
+}
+
+// 站点地图根元素
+type sitemapURLSet struct {
+    XMLName xml.Name     `xml:"urlset"`
+    Xmlns   string       `xml:"xmlns,attr"`
+    URLs    []sitemapURL `xml:"url"`
+}
+
+// 将 baseHost 主机上爬取成功 (2xx 或 304) 的页面写入 sitemap.xml
+func writeSitemap(filename string, results []PageData, baseHost string) error {
+    urlSet := sitemapURLSet{Xmlns: "http://www.sitemaps.org/schemas/sitemap/0.9"}
+    seen := make(map[string]bool)
+    for _, page := range results {
+        pageURL, err := url.Parse(page.URL)
+        if !page.fetched() || err != nil || pageURL.Host != baseHost || seen[page.URL] {
+            continue
+        }
+        seen[page.URL] = true
+
+        entry := sitemapURL{Loc: page.URL}
+        if modified, err := http.ParseTime(page.LastModified); err == nil {
+            entry.LastMod = modified.UTC().Format(time.RFC3339)
+        }
+        urlSet.URLs = append(urlSet.URLs, entry)
+    }
+
+    file, err := os.Create(filename)
+    if err != nil {
+        return err
+    }
+    defer file.Close()
+
+    if _, err := io.WriteString(file, xml.Header); err != nil {
+        return err
+    }
+    encoder := xml.NewEncoder(file)
+    encoder.Indent("", "  ")
+    if err := encoder.Encode(urlSet); err != nil {
+        return err
+    }
+    _, err = io.WriteString(file, "\n")
+    return err
+}
+
+// 将发现的 URL 逐行写入文件
+func writeVisited(filename string, urls []string) error {
+    file, err := os.Create(filename)
+    if err != nil {
+        return err
+    }
+    defer file.Close()
+
+    for _, u := range urls {
+        if _, err := fmt.Fprintln(file, u); err != nil {
+            return err
+        }
+    }
+
+    return nil
+}
@@ -0,0 +1,1198 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 测试单页链接上限只将前 N 个链接加入队列
+func TestEnqueueLinksMaxLinksPerPage(t *testing.T) {
+    config := CrawlerConfig{
+        StartURL:        "http://example.com/",
+        MaxDepth:        1,
+        MaxURLs:         1000,
+        SameHost:        true,
+        MaxLinksPerPage: 5,
+    }
+
+    // 构造包含大量链接的页面
+    var links []string
+    for i := 0; i < 200; i++ {
+        links = append(links, fmt.Sprintf("/page%d", i))
+    }
+
+    front := newFrontier(config.MaxURLs)
+    page := PageData{URL: config.StartURL, Depth: 0}
+    enqueued, truncated := enqueueLinks(context.Background(), front, page, links, config, "example.com")
+
+    if enqueued != 5 {
+        t.Errorf("应该只加入5个链接，但实际加入了 %d 个", enqueued)
+    }
+    if !truncated {
+        t.Errorf("链接超过上限时应该报告已截断")
+    }
+    if len(front.queue) != 5 {
+        t.Errorf("队列中应该有5个链接，但实际有 %d 个", len(front.queue))
+    }
+
+    // 取前 N 个链接
+    first := <-front.queue
+    if first.URL != "http://example.com/page0" || first.Depth != 1 {
+        t.Errorf("第一个入队的链接应该是 page0，得到 %s (深度 %d)", first.URL, first.Depth)
+    }
+
+    // 不设置上限时全部加入队列
+    config.MaxLinksPerPage = 0
+    front = newFrontier(config.MaxURLs)
+    enqueued, truncated = enqueueLinks(context.Background(), front, page, links, config, "example.com")
+    if enqueued != 200 || truncated {
+        t.Errorf("不限制时应该加入全部200个链接，得到 %d 个 (截断: %v)", enqueued, truncated)
+    }
+}
+
+// 测试请求间隔在抖动范围内随机变化
+func TestJitteredDelay(t *testing.T) {
+    base := 100 * time.Millisecond
+    jitter := 0.3
+    upper := time.Duration(float64(base) * (1 + jitter))
+
+    seen := make(map[time.Duration]bool)
+    for i := 0; i < 1000; i++ {
+        d := jitteredDelay(base, jitter)
+        if d < base || d > upper {
+            t.Fatalf("间隔 %v 超出抖动范围 [%v, %v]", d, base, upper)
+        }
+        seen[d] = true
+    }
+
+    // 多次请求的间隔应该各不相同
+    if len(seen) < 100 {
+        t.Errorf("间隔应该随机变化，但只出现了 %d 种不同的值", len(seen))
+    }
+
+    // 不设置抖动时使用基础间隔
+    if d := jitteredDelay(base, 0); d != base {
+        t.Errorf("无抖动时间隔应为 %v，得到 %v", base, d)
+    }
+}
+
+// 测试限速器的间隔不小于最小间隔，抖动在其上分布于整个范围
+func TestHostLimiterInterval(t *testing.T) {
+    delay := 100 * time.Millisecond
+    limiter := newHostLimiter(delay, 0.5)
+    upper := delay + delay/2
+
+    var minSeen, maxSeen time.Duration
+    for i := 0; i < 1000; i++ {
+        d := limiter.interval()
+        if d < delay || d > upper {
+            t.Fatalf("间隔 %v 超出范围 [%v, %v]", d, delay, upper)
+        }
+        if i == 0 || d < minSeen {
+            minSeen = d
+        }
+        if d > maxSeen {
+            maxSeen = d
+        }
+    }
+
+    // 1000 次抽样应覆盖范围的两端
+    if minSeen > delay+delay/10 || maxSeen < upper-delay/10 {
+        t.Errorf("间隔应分布在 [%v, %v]，实际范围 [%v, %v]", delay, upper, minSeen, maxSeen)
+    }
+
+    if d := newHostLimiter(delay, 0).interval(); d != delay {
+        t.Errorf("无抖动时间隔应为 %v，得到 %v", delay, d)
+    }
+}
+
+// 测试超出深度限制的链接不加入队列，不占用 MaxURLs 的名额
+func TestEnqueueLinksMaxDepth(t *testing.T) {
+    config := CrawlerConfig{
+        StartURL: "http://example.com/",
+        MaxDepth: 1,
+        MaxURLs:  3,
+        SameHost: true,
+    }
+    front := newFrontier(config.MaxURLs)
+    front.push(PageData{URL: config.StartURL})
+
+    // 深度 1 的页面上的链接深度为 2，不会被爬取
+    deep := PageData{URL: "http://example.com/a", Depth: 1}
+    if n, _ := enqueueLinks(context.Background(), front, deep, []string{"/a1", "/a2", "/a3"}, config, "example.com"); n != 0 {
+        t.Errorf("超出深度限制的链接不应加入，得到 %d 个", n)
+    }
+    if discovered := front.discoveredURLs(); len(discovered) != 1 {
+        t.Errorf("超出深度限制的链接不应计入发现的 URL，得到 %v", discovered)
+    }
+
+    // 剩余名额留给深度范围内的链接
+    root := PageData{URL: config.StartURL, Depth: 0}
+    if n, _ := enqueueLinks(context.Background(), front, root, []string{"/b", "/c", "/d"}, config, "example.com"); n != 2 {
+        t.Errorf("应加入2个深度范围内的链接直到达到 URL 上限，得到 %d 个", n)
+    }
+}
+
+// 测试主机允许/禁止列表过滤发现的链接
+func TestEnqueueLinksHostLists(t *testing.T) {
+    config := CrawlerConfig{
+        StartURL:   "http://a.com/",
+        MaxDepth:   1,
+        MaxURLs:    100,
+        SameHost:   false,
+        AllowHosts: parseHostList("a.com, B.com"),
+        DenyHosts:  parseHostList("blocked.a.com"),
+    }
+    links := []string{
+        "http://a.com/page",
+        "http://docs.a.com/guide",
+        "http://b.com/about",
+        "http://blocked.a.com/secret",
+        "http://c.com/other",
+        "http://nota.com/fake",
+    }
+
+    front := newFrontier(config.MaxURLs)
+    enqueueLinks(context.Background(), front, PageData{URL: config.StartURL}, links, config, "a.com")
+    close(front.queue)
+
+    var got []string
+    for page := range front.queue {
+        got = append(got, page.URL)
+    }
+
+    expected := []string{"http://a.com/page", "http://docs.a.com/guide", "http://b.com/about"}
+    if len(got) != len(expected) {
+        t.Fatalf("应该加入 %v，但实际加入 %v", expected, got)
+    }
+    for i := range expected {
+        if got[i] != expected[i] {
+            t.Errorf("第 %d 个链接应为 %s，得到 %s", i+1, expected[i], got[i])
+        }
+    }
+}
+
+// 测试提交表单后从响应中发现链接
+func TestSubmitFormSeed(t *testing.T) {
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        r.ParseForm()
+        if r.Form.Get("q") != "golang" {
+            fmt.Fprint(w, "<html><body>没有结果</body></html>")
+            return
+        }
+        fmt.Fprint(w, `<html><head><title>搜索结果</title></head><body>
+            <a href="/result/1">结果1</a>
+            <a href="/result/2">结果2</a>
+        </body></html>`)
+    }))
+    defer server.Close()
+
+    for _, method := range []string{http.MethodGet, http.MethodPost} {
+        t.Run(method, func(t *testing.T) {
+            fields, err := parseFormFields([]string{"q=golang"})
+            if err != nil {
+                t.Fatalf("解析表单字段失败: %v", err)
+            }
+            config := CrawlerConfig{
+                FormURL:    server.URL + "/search",
+                FormMethod: method,
+                FormFields: fields,
+                Timeout:    5 * time.Second,
+                MaxDepth:   1,
+                MaxURLs:    10,
+                SameHost:   true,
+            }
+
+            seed := submitForm(config)
+            if seed.Error != nil {
+                t.Fatalf("提交表单失败: %v", seed.Error)
+            }
+            if seed.Title != "搜索结果" {
+                t.Errorf("种子页面标题应为 搜索结果，得到 %q", seed.Title)
+            }
+
+            baseURL, _ := url.Parse(server.URL)
+            front := newFrontier(config.MaxURLs)
+            n, _ := enqueueLinks(context.Background(), front, seed, seed.Links, config, baseURL.Host)
+            if n != 2 {
+                t.Fatalf("应该从表单响应中发现2个链接，得到 %d 个", n)
+            }
+            first := <-front.queue
+            if first.URL != server.URL+"/result/1" {
+                t.Errorf("发现的链接应为 %s，得到 %s", server.URL+"/result/1", first.URL)
+            }
+        })
+    }
+
+    // 错误的查询不返回链接
+    fields, _ := parseFormFields([]string{"q=rust"})
+    seed := submitForm(CrawlerConfig{FormURL: server.URL, FormFields: fields, Timeout: 5 * time.Second})
+    if len(seed.Links) != 0 {
+        t.Errorf("错误的查询不应返回链接，得到 %v", seed.Links)
+    }
+}
+
+// 测试发现的 URL 记录包含超出爬取上限的链接
+func TestVisitedDumpBeyondMaxURLs(t *testing.T) {
+    config := CrawlerConfig{
+        StartURL: "http://example.com/",
+        MaxDepth: 1,
+        MaxURLs:  3,
+        SameHost: true,
+    }
+
+    front := newFrontier(config.MaxURLs)
+    front.push(PageData{URL: config.StartURL})
+
+    var links []string
+    for i := 0; i < 10; i++ {
+        links = append(links, fmt.Sprintf("/page%d", i))
+    }
+    enqueueLinks(context.Background(), front, PageData{URL: config.StartURL}, links, config, "example.com")
+
+    if len(front.queue) != config.MaxURLs {
+        t.Errorf("队列中应该只有 %d 个 URL，得到 %d 个", config.MaxURLs, len(front.queue))
+    }
+
+    discovered := front.discoveredURLs()
+    if len(discovered) != 11 {
+        t.Fatalf("应该发现11个 URL (起始页 + 10 个链接)，得到 %d 个", len(discovered))
+    }
+
+    visitedFile := filepath.Join(t.TempDir(), "visited.txt")
+    if err := writeVisited(visitedFile, discovered); err != nil {
+        t.Fatalf("写入发现的 URL 失败: %v", err)
+    }
+    content, err := os.ReadFile(visitedFile)
+    if err != nil {
+        t.Fatalf("读取发现的 URL 失败: %v", err)
+    }
+    lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+    if len(lines) != 11 {
+        t.Errorf("文件中应该有11行，得到 %d 行", len(lines))
+    }
+    if !strings.Contains(string(content), "http://example.com/page9") {
+        t.Errorf("文件中应包含超出上限的 page9")
+    }
+}
+
+// 测试重新爬取时 304 响应的页面标记为未变化并沿用上次的结果
+func TestFetchPageConditionalGet(t *testing.T) {
+    const etag = `"v1"`
+    const lastModified = "Mon, 02 Jan 2006 15:04:05 GMT"
+    requests := 0
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        requests++
+        if r.Header.Get("If-None-Match") == etag && r.Header.Get("If-Modified-Since") == lastModified {
+            w.WriteHeader(http.StatusNotModified)
+            return
+        }
+        w.Header().Set("ETag", etag)
+        w.Header().Set("Last-Modified", lastModified)
+        fmt.Fprint(w, `<html><head><title>首页</title><meta name="description" content="站点首页"></head>`+
+            `<body><h1>欢迎</h1><img src="/logo.png"><a href="/a">A</a></body></html>`)
+    }))
+    defer server.Close()
+
+    cacheFile := filepath.Join(t.TempDir(), "cache.json")
+    cache, err := loadPageCache(cacheFile)
+    if err != nil {
+        t.Fatalf("读取不存在的缓存文件应返回空缓存: %v", err)
+    }
+
+    first := fetchPage(context.Background(), server.URL, CrawlerConfig{Timeout: 5 * time.Second, Cache: cache}, nil)
+    if first.Error != nil || first.Unchanged {
+        t.Fatalf("首次爬取应正常解析页面，得到 %+v", first)
+    }
+    if err := cache.save(cacheFile); err != nil {
+        t.Fatalf("保存缓存失败: %v", err)
+    }
+
+    // 从文件重新加载，模拟下一次爬取
+    cache, err = loadPageCache(cacheFile)
+    if err != nil {
+        t.Fatalf("读取缓存失败: %v", err)
+    }
+    second := fetchPage(context.Background(), server.URL, CrawlerConfig{Timeout: 5 * time.Second, Cache: cache}, nil)
+    if second.Error != nil {
+        t.Fatalf("重新爬取失败: %v", second.Error)
+    }
+    if !second.Unchanged {
+        t.Errorf("304 响应的页面应标记为未变化")
+    }
+    if second.Title != "首页" || len(second.Links) != 1 || second.Links[0] != "/a" {
+        t.Errorf("未变化的页面应沿用上次的标题和链接，得到 %q %v", second.Title, second.Links)
+    }
+    if second.Description != "站点首页" || len(second.Headings) != 1 || second.Headings[0] != "欢迎" {
+        t.Errorf("未变化的页面应沿用上次的描述和一级标题，得到 %q %v", second.Description, second.Headings)
+    }
+    if len(second.Images) != 1 || second.Images[0] != server.URL+"/logo.png" {
+        t.Errorf("未变化的页面应沿用上次的图片，得到 %v", second.Images)
+    }
+    if requests != 2 {
+        t.Errorf("应该发出2次请求，得到 %d 次", requests)
+    }
+
+    // 不使用缓存时不发送条件请求
+    if page := fetchPage(context.Background(), server.URL, CrawlerConfig{Timeout: 5 * time.Second}, nil); page.Unchanged {
+        t.Errorf("没有缓存时不应标记为未变化")
+    }
+}
+
+// 测试预设设置文档中列出的值，且显式参数优先
+func TestApplyProfile(t *testing.T) {
+    testCases := []struct {
+        name            string
+        concurrent      int
+        delay           time.Duration
+        jitter          float64
+        maxLinksPerPage int
+    }{
+        {"gentle", 1, 2 * time.Second, 0.5, 20},
+        {"normal", 5, 500 * time.Millisecond, 0.3, 0},
+        {"aggressive", 20, 0, 0, 0},
+    }
+
+    for _, tc := range testCases {
+        t.Run(tc.name, func(t *testing.T) {
+            config := CrawlerConfig{Concurrent: 5, Jitter: 0.3}
+            if err := applyProfile(&config, tc.name, nil); err != nil {
+                t.Fatalf("应用预设失败: %v", err)
+            }
+            if config.Concurrent != tc.concurrent || config.Delay != tc.delay ||
+                config.Jitter != tc.jitter || config.MaxLinksPerPage != tc.maxLinksPerPage {
+                t.Errorf("预设 %s 的配置不正确: 并发 %d, 间隔 %v, 抖动 %v, 单页链接 %d",
+                    tc.name, config.Concurrent, config.Delay, config.Jitter, config.MaxLinksPerPage)
+            }
+        })
+    }
+
+    // 显式指定的参数不被预设覆盖
+    config := CrawlerConfig{Concurrent: 3, Delay: 100 * time.Millisecond, Jitter: 0.1}
+    explicit := map[string]bool{"concurrent": true, "delay": true}
+    if err := applyProfile(&config, "gentle", explicit); err != nil {
+        t.Fatalf("应用预设失败: %v", err)
+    }
+    if config.Concurrent != 3 || config.Delay != 100*time.Millisecond {
+        t.Errorf("显式指定的并发和间隔应保留，得到 %d 和 %v", config.Concurrent, config.Delay)
+    }
+    if config.Jitter != 0.5 || config.MaxLinksPerPage != 20 {
+        t.Errorf("未指定的参数应使用预设值，得到抖动 %v 和单页链接 %d", config.Jitter, config.MaxLinksPerPage)
+    }
+
+    if err := applyProfile(&config, "turbo", nil); err == nil {
+        t.Errorf("未知的预设应该报错")
+    }
+}
+
+// 测试只记录 -max-pages 个页面，而发现的 URL 可以更多
+func TestCrawlMaxPages(t *testing.T) {
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        fmt.Fprintf(w, "<html><head><title>%s</title></head><body>", r.URL.Path)
+        for i := 0; i < 5; i++ {
+            fmt.Fprintf(w, `<a href="%s/%d">link</a>`, strings.TrimSuffix(r.URL.Path, "/"), i)
+        }
+        fmt.Fprint(w, "</body></html>")
+    }))
+    defer server.Close()
+
+    config := CrawlerConfig{
+        StartURL:   server.URL + "/",
+        MaxDepth:   3,
+        MaxURLs:    50,
+        MaxPages:   3,
+        SameHost:   true,
+        Timeout:    5 * time.Second,
+        Concurrent: 1,
+    }
+    results, discovered := crawl(context.Background(), config)
+
+    if len(results) != config.MaxPages {
+        t.Errorf("应该记录 %d 个页面，得到 %d 个", config.MaxPages, len(results))
+    }
+    if len(discovered) <= config.MaxPages {
+        t.Errorf("发现的 URL 应该多于记录的页面数，得到 %d 个", len(discovered))
+    }
+
+    // 不设置 MaxPages 时使用 MaxURLs
+    if limit := (CrawlerConfig{MaxURLs: 7}).pageLimit(); limit != 7 {
+        t.Errorf("未设置 MaxPages 时上限应为 MaxURLs，得到 %d", limit)
+    }
+}
+
+// 测试 robots.txt 禁止的链接不加入队列，且每个主机只请求一次 robots.txt
+func TestRobotsDisallow(t *testing.T) {
+    robotsRequests := 0
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if r.URL.Path == "/robots.txt" {
+            robotsRequests++
+            fmt.Fprint(w, "User-agent: googlebot\nDisallow: /public\n\n"+
+                "User-agent: other\nUser-agent: *\nDisallow: /private # 私有页面\nDisallow:\n")
+            return
+        }
+        fmt.Fprint(w, "<html></html>")
+    }))
+    defer server.Close()
+
+    baseURL, _ := url.Parse(server.URL)
+    config := CrawlerConfig{
+        StartURL: server.URL + "/",
+        MaxDepth: 1,
+        MaxURLs:  100,
+        SameHost: true,
+        Robots:   newRobotsCache(),
+    }
+    links := []string{"/public/a", "/private/b", "/private", "/about"}
+
+    front := newFrontier(config.MaxURLs)
+    n, _ := enqueueLinks(context.Background(), front, PageData{URL: config.StartURL}, links, config, baseURL.Host)
+    if n != 2 {
+        t.Fatalf("应该加入2个未被禁止的链接，得到 %d 个", n)
+    }
+    if first := <-front.queue; first.URL != server.URL+"/public/a" {
+        t.Errorf("其他爬虫分组的规则不应生效，第一个链接应为 /public/a，得到 %s", first.URL)
+    }
+
+    // 同一主机再次检查使用缓存的规则
+    enqueueLinks(context.Background(), front, PageData{URL: config.StartURL}, []string{"/contact"}, config, baseURL.Host)
+    if robotsRequests != 1 {
+        t.Errorf("每个主机应只请求一次 robots.txt，得到 %d 次", robotsRequests)
+    }
+
+    // 忽略 robots.txt 时全部加入
+    config.Robots = nil
+    front = newFrontier(config.MaxURLs)
+    if n, _ := enqueueLinks(context.Background(), front, PageData{URL: config.StartURL}, links, config, baseURL.Host); n != 4 {
+        t.Errorf("忽略 robots.txt 时应加入全部4个链接，得到 %d 个", n)
+    }
+}
+
+// 测试 robots.txt 在锁外获取：同一主机只请求一次，慢主机不阻塞其他主机，并按路径和查询参数匹配
+func TestRobotsFetchOutsideLock(t *testing.T) {
+    var mu sync.Mutex
+    slowRequests := 0
+    var userAgents []string
+    release := make(chan struct{})
+    slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        mu.Lock()
+        slowRequests++
+        userAgents = append(userAgents, r.Header.Get("User-Agent"))
+        mu.Unlock()
+        <-release
+        fmt.Fprint(w, "User-agent: *\nDisallow: /search?q=\n")
+    }))
+    defer slow.Close()
+    fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        fmt.Fprint(w, "User-agent: *\nDisallow: /private\n")
+    }))
+    defer fast.Close()
+
+    cache := newRobotsCache()
+    config := CrawlerConfig{UserAgent: "test-spider/1.0"}
+    parse := func(raw string) *url.URL {
+        u, err := url.Parse(raw)
+        if err != nil {
+            t.Fatalf("解析 URL 失败: %v", err)
+        }
+        return u
+    }
+
+    // 慢主机的两个检查并发进行，都等待同一次请求
+    var wg sync.WaitGroup
+    results := make([]bool, 2)
+    for i, link := range []string{slow.URL + "/search?q=go", slow.URL + "/search"} {
+        wg.Add(1)
+        go func(i int, link string) {
+            defer wg.Done()
+            results[i] = cache.allowed(context.Background(), parse(link), config)
+        }(i, link)
+    }
+
+    // 慢主机的请求未完成时，其他主机的检查不受影响
+    checked := make(chan bool, 1)
+    go func() {
+        checked <- cache.allowed(context.Background(), parse(fast.URL+"/private/a"), config)
+    }()
+    select {
+    case allowed := <-checked:
+        if allowed {
+            t.Error("/private/a 应被禁止")
+        }
+    case <-time.After(2 * time.Second):
+        t.Fatal("获取慢主机的 robots.txt 时阻塞了其他主机的检查")
+    }
+
+    close(release)
+    wg.Wait()
+    if results[0] || !results[1] {
+        t.Errorf("/search?q=go 应被禁止而 /search 允许，得到 %v", results)
+    }
+    if slowRequests != 1 {
+        t.Errorf("并发检查同一主机时应只请求一次 robots.txt，得到 %d 次", slowRequests)
+    }
+    if len(userAgents) != 1 || userAgents[0] != config.UserAgent {
+        t.Errorf("robots.txt 请求应使用配置的 User-Agent，得到 %v", userAgents)
+    }
+
+    // ctx 已取消时不加入链接，也不缓存结果
+    ctx, cancel := context.WithCancel(context.Background())
+    cancel()
+    other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        fmt.Fprint(w, "")
+    }))
+    defer other.Close()
+    if cache.allowed(ctx, parse(other.URL+"/"), config) {
+        t.Error("ctx 取消后不应允许爬取")
+    }
+    if !cache.allowed(context.Background(), parse(other.URL+"/"), config) {
+        t.Error("取消的请求不应被缓存，重新获取后应允许爬取")
+    }
+}
+
+// 测试同一主机的请求保持最小间隔，不同主机的请求不受影响
+func TestHostLimiter(t *testing.T) {
+    var mu sync.Mutex
+    var times []time.Time
+    slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        mu.Lock()
+        times = append(times, time.Now())
+        mu.Unlock()
+        fmt.Fprint(w, "<html></html>")
+    }))
+    defer slow.Close()
+    other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        fmt.Fprint(w, "<html></html>")
+    }))
+    defer other.Close()
+
+    delay := 100 * time.Millisecond
+    limiter := newHostLimiter(delay, 0.3)
+
+    var wg sync.WaitGroup
+    for i := 0; i < 3; i++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            fetchPage(context.Background(), slow.URL, CrawlerConfig{Timeout: 5 * time.Second}, limiter)
+        }()
+    }
+
+    // 等第一个请求预约之后，另一个主机的请求应立即发出
+    time.Sleep(10 * time.Millisecond)
+    start := time.Now()
+    fetchPage(context.Background(), other.URL, CrawlerConfig{Timeout: 5 * time.Second}, limiter)
+    if elapsed := time.Since(start); elapsed >= delay {
+        t.Errorf("不同主机的请求不应等待，耗时 %v", elapsed)
+    }
+    wg.Wait()
+
+    if len(times) != 3 {
+        t.Fatalf("应该收到3个请求，得到 %d 个", len(times))
+    }
+    sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+    for i := 1; i < len(times); i++ {
+        if gap := times[i].Sub(times[i-1]); gap < delay-10*time.Millisecond {
+            t.Errorf("同一主机第 %d 次请求的间隔 %v 小于最小间隔 %v", i+1, gap, delay)
+        }
+    }
+
+    // 抖动只增加间隔
+    for i := 0; i < 100; i++ {
+        if d := limiter.interval(); d < delay || d > time.Duration(float64(delay)*1.3) {
+            t.Fatalf("间隔 %v 超出范围 [%v, %v]", d, delay, time.Duration(float64(delay)*1.3))
+        }
+    }
+}
+
+// 测试队列爬空后正常结束，不需要达到 MaxURLs
+func TestCrawlTerminatesWhenFrontierEmpty(t *testing.T) {
+    site := map[string][]string{
+        "/":  {"/a", "/b"},
+        "/a": {"/c", "/"},
+        "/b": {"/c"},
+        "/c": nil,
+    }
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        links, ok := site[r.URL.Path]
+        if !ok {
+            http.NotFound(w, r)
+            return
+        }
+        fmt.Fprintf(w, "<html><head><title>%s</title></head><body>", r.URL.Path)
+        for _, link := range links {
+            fmt.Fprintf(w, `<a href="%s">%s</a>`, link, link)
+        }
+        fmt.Fprint(w, "</body></html>")
+    }))
+    defer server.Close()
+
+    for _, concurrent := range []int{1, 3} {
+        config := CrawlerConfig{
+            StartURL:   server.URL + "/",
+            MaxDepth:   5,
+            MaxURLs:    100,
+            SameHost:   true,
+            Timeout:    5 * time.Second,
+            Concurrent: concurrent,
+        }
+
+        done := make(chan []PageData)
+        go func() {
+            results, _ := crawl(context.Background(), config)
+            done <- results
+        }()
+
+        select {
+        case results := <-done:
+            if len(results) != len(site) {
+                t.Errorf("并发 %d: 应该爬取 %d 个页面，得到 %d 个", concurrent, len(site), len(results))
+            }
+            titles := make(map[string]bool)
+            for _, page := range results {
+                if page.Error != nil {
+                    t.Errorf("并发 %d: 爬取 %s 失败: %v", concurrent, page.URL, page.Error)
+                }
+                titles[page.Title] = true
+            }
+            for path := range site {
+                if !titles[path] {
+                    t.Errorf("并发 %d: 缺少页面 %s", concurrent, path)
+                }
+            }
+        case <-time.After(5 * time.Second):
+            t.Fatalf("并发 %d: 队列为空后爬取没有结束", concurrent)
+        }
+    }
+}
+
+// 测试爬取结果记录来源页面，并写入CSV的来源列
+func TestCrawlRecordsParent(t *testing.T) {
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if r.URL.Path == "/" {
+            fmt.Fprint(w, `<html><body><a href="/child">child</a></body></html>`)
+            return
+        }
+        fmt.Fprint(w, "<html><head><title>子页面</title></head></html>")
+    }))
+    defer server.Close()
+
+    results, _ := crawl(context.Background(), CrawlerConfig{
+        StartURL:   server.URL + "/",
+        MaxDepth:   2,
+        MaxURLs:    10,
+        SameHost:   true,
+        Timeout:    5 * time.Second,
+        Concurrent: 2,
+    })
+    parents := make(map[string]string)
+    for _, page := range results {
+        parents[page.URL] = page.Parent
+    }
+    if parent, ok := parents[server.URL+"/"]; !ok || parent != "" {
+        t.Errorf("起始页的来源应为空，得到 %q (存在: %v)", parent, ok)
+    }
+    if parents[server.URL+"/child"] != server.URL+"/" {
+        t.Errorf("子页面的来源应为起始页，得到 %q", parents[server.URL+"/child"])
+    }
+
+    outputFile := filepath.Join(t.TempDir(), "results.csv")
+    if err := writeResults(outputFile, results); err != nil {
+        t.Fatalf("写入结果失败: %v", err)
+    }
+    content, err := os.ReadFile(outputFile)
+    if err != nil {
+        t.Fatalf("读取结果失败: %v", err)
+    }
+    if !strings.HasPrefix(string(content), "URL,来源,标题,描述,一级标题,深度,链接数,图片,错误\n") {
+        t.Errorf("CSV表头应包含来源列:\n%s", content)
+    }
+    edge := fmt.Sprintf(`"%s/child","%s/","子页面","","",1,0,"",""`, server.URL, server.URL)
+    if !strings.Contains(string(content), edge) {
+        t.Errorf("CSV中缺少子页面的来源记录 %s:\n%s", edge, content)
+    }
+}
+
+// 测试提取页面描述、一级标题和图片，图片地址按链接的方式解析
+func TestParseHTMLMeta(t *testing.T) {
+    body := `<html><head><title>产品</title>
+        <meta name="Description" content=" 产品介绍页 ">
+        </head><body>
+        <h1>欢迎 <span>光临</span></h1>
+        <h1>  </h1>
+        <img src="/img/logo.png"><img src="thumb.jpg"><img src="https://cdn.example.com/a.png"><img alt="无地址">
+        <a href="/about">关于</a>
+        </body></html>`
+
+    page := parseHTML("http://example.com/products/index.html", strings.NewReader(body))
+    if page.Error != nil {
+        t.Fatalf("解析页面失败: %v", page.Error)
+    }
+    if page.Description != "产品介绍页" {
+        t.Errorf("描述应为 产品介绍页，得到 %q", page.Description)
+    }
+    if len(page.Headings) != 1 || page.Headings[0] != "欢迎 光临" {
+        t.Errorf("一级标题应为 [欢迎 光临]，得到 %q", page.Headings)
+    }
+    expected := []string{
+        "http://example.com/img/logo.png",
+        "http://example.com/products/thumb.jpg",
+        "https://cdn.example.com/a.png",
+    }
+    if strings.Join(page.Images, ",") != strings.Join(expected, ",") {
+        t.Errorf("图片地址应为 %v，得到 %v", expected, page.Images)
+    }
+}
+
+// 测试站点地图使用实际爬取的主机，提交表单时为表单地址的主机
+func TestConfigBaseHost(t *testing.T) {
+    config := CrawlerConfig{StartURL: "http://example.com/"}
+    if host := config.baseHost(); host != "example.com" {
+        t.Errorf("应使用起始 URL 的主机，得到 %s", host)
+    }
+    config.FormURL = "http://search.example.org:8080/query"
+    if host := config.baseHost(); host != "search.example.org:8080" {
+        t.Errorf("提交表单时应使用表单地址的主机，得到 %s", host)
+    }
+
+    results := []PageData{
+        {URL: "http://search.example.org:8080/query", StatusCode: http.StatusOK},
+        {URL: "http://search.example.org:8080/item/1", StatusCode: http.StatusOK},
+        {URL: "http://example.com/", StatusCode: http.StatusOK},
+    }
+    sitemapFile := filepath.Join(t.TempDir(), "sitemap.xml")
+    if err := writeSitemap(sitemapFile, results, config.baseHost()); err != nil {
+        t.Fatalf("写入站点地图失败: %v", err)
+    }
+    content, err := os.ReadFile(sitemapFile)
+    if err != nil {
+        t.Fatalf("读取站点地图失败: %v", err)
+    }
+    if strings.Count(string(content), "<loc>") != 2 || strings.Contains(string(content), "example.com/") {
+        t.Errorf("站点地图应只包含表单主机的2个页面:\n%s", content)
+    }
+}
+
+// 测试站点地图只包含同主机且爬取成功 (2xx 或 304) 的页面，并带有 lastmod
+func TestWriteSitemap(t *testing.T) {
+    // 4xx 页面没有请求错误，但不算爬取成功
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        w.WriteHeader(http.StatusNotFound)
+        fmt.Fprint(w, `<html><body><a href="/">首页</a></body></html>`)
+    }))
+    defer server.Close()
+    missing := fetchPage(context.Background(), server.URL+"/missing", CrawlerConfig{Timeout: 5 * time.Second}, nil)
+    if missing.Error != nil || missing.StatusCode != http.StatusNotFound || missing.fetched() {
+        t.Fatalf("404 页面应记录状态码且不算爬取成功，得到 %+v", missing)
+    }
+    if len(missing.Links) != 1 {
+        t.Errorf("404 页面中的链接仍应被解析，得到 %v", missing.Links)
+    }
+
+    results := []PageData{
+        {URL: "http://example.com/", LastModified: "Mon, 02 Jan 2006 15:04:05 GMT", StatusCode: http.StatusOK},
+        {URL: "http://example.com/about?a=1&b=2", StatusCode: http.StatusOK},
+        {URL: "http://example.com/cached", StatusCode: http.StatusNotModified, Unchanged: true},
+        {URL: "http://example.com/broken", Error: fmt.Errorf("超时")},
+        {URL: "http://example.com/missing", StatusCode: http.StatusNotFound},
+        {URL: "http://example.com/gone", StatusCode: http.StatusGone},
+        {URL: "http://example.com/forbidden", StatusCode: http.StatusForbidden},
+        {URL: "http://other.com/page", StatusCode: http.StatusOK},
+    }
+
+    sitemapFile := filepath.Join(t.TempDir(), "sitemap.xml")
+    if err := writeSitemap(sitemapFile, results, "example.com"); err != nil {
+        t.Fatalf("写入站点地图失败: %v", err)
+    }
+    content, err := os.ReadFile(sitemapFile)
+    if err != nil {
+        t.Fatalf("读取站点地图失败: %v", err)
+    }
+    sitemap := string(content)
+
+    for _, want := range []string{
+        `<?xml version="1.0" encoding="UTF-8"?>`,
+        `<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">`,
+        "<loc>http://example.com/</loc>",
+        "<lastmod>2006-01-02T15:04:05Z</lastmod>",
+        "<loc>http://example.com/about?a=1&amp;b=2</loc>",
+        "<loc>http://example.com/cached</loc>",
+    } {
+        if !strings.Contains(sitemap, want) {
+            t.Errorf("站点地图缺少 %s:\n%s", want, sitemap)
+        }
+    }
+    for _, unwanted := range []string{"broken", "missing", "gone", "forbidden", "other.com"} {
+        if strings.Contains(sitemap, unwanted) {
+            t.Errorf("站点地图不应包含失败、非 2xx/304 或其他主机的页面 %s:\n%s", unwanted, sitemap)
+        }
+    }
+    if strings.Count(sitemap, "<lastmod>") != 1 {
+        t.Errorf("没有 Last-Modified 的页面不应有 lastmod:\n%s", sitemap)
+    }
+}
+
+// 测试 5xx 和网络错误按指数退避重试，4xx 不重试
+func TestFetchPageRetries(t *testing.T) {
+    var mu sync.Mutex
+    hits := make(map[string]int)
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        mu.Lock()
+        hits[r.URL.Path]++
+        n := hits[r.URL.Path]
+        mu.Unlock()
+
+        switch r.URL.Path {
+        case "/flaky":
+            // 前两次返回 503
+            if n <= 2 {
+                w.WriteHeader(http.StatusServiceUnavailable)
+                return
+            }
+            fmt.Fprint(w, "<html><head><title>恢复</title></head></html>")
+        case "/down":
+            w.WriteHeader(http.StatusInternalServerError)
+        default:
+            http.NotFound(w, r)
+        }
+    }))
+    defer server.Close()
+
+    config := CrawlerConfig{Timeout: 5 * time.Second, Retries: 3, RetryBackoff: 10 * time.Millisecond}
+
+    page := fetchPage(context.Background(), server.URL+"/flaky", config, nil)
+    if page.Error != nil || page.Title != "恢复" || page.Attempts != 3 {
+        t.Errorf("重试后应成功且请求3次，得到错误 %v、标题 %q、请求 %d 次", page.Error, page.Title, page.Attempts)
+    }
+
+    page = fetchPage(context.Background(), server.URL+"/down", config, nil)
+    if page.Error == nil || page.Attempts != 4 {
+        t.Errorf("持续 5xx 时应在重试3次后报错，得到错误 %v、请求 %d 次", page.Error, page.Attempts)
+    }
+
+    page = fetchPage(context.Background(), server.URL+"/missing", config, nil)
+    if page.Attempts != 1 || hits["/missing"] != 1 {
+        t.Errorf("4xx 不应重试，得到请求 %d 次", page.Attempts)
+    }
+
+    // 网络错误同样重试，总耗时受超时限制
+    server.Close()
+    start := time.Now()
+    page = fetchPage(context.Background(), server.URL+"/flaky", CrawlerConfig{Timeout: 50 * time.Millisecond, Retries: 2, RetryBackoff: time.Hour}, nil)
+    if page.Error == nil || page.Attempts != 1 {
+        t.Errorf("退避超过截止时间时应停止重试，得到错误 %v、请求 %d 次", page.Error, page.Attempts)
+    }
+    if elapsed := time.Since(start); elapsed > time.Second {
+        t.Errorf("重试总耗时应受超时限制，耗时 %v", elapsed)
+    }
+}
+
+// 测试包含/排除正则只作用于发现的链接，起始 URL 总会被爬取
+func TestCrawlIncludeExclude(t *testing.T) {
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        fmt.Fprint(w, "<html><body>")
+        if r.URL.Path == "/" {
+            fmt.Fprint(w, `<a href="/docs/intro">intro</a><a href="/docs/login">login</a><a href="/about">about</a>`)
+        }
+        fmt.Fprint(w, "</body></html>")
+    }))
+    defer server.Close()
+
+    config := CrawlerConfig{
+        StartURL:   server.URL + "/",
+        MaxDepth:   2,
+        MaxURLs:    50,
+        SameHost:   true,
+        Timeout:    5 * time.Second,
+        Concurrent: 1,
+        Include:    regexp.MustCompile(`/docs/`),
+        Exclude:    regexp.MustCompile(`/login`),
+    }
+    results, _ := crawl(context.Background(), config)
+
+    var got []string
+    for _, page := range results {
+        got = append(got, strings.TrimPrefix(page.URL, server.URL))
+    }
+    sort.Strings(got)
+    if strings.Join(got, ",") != "/,/docs/intro" {
+        t.Errorf("应只爬取起始页面和 /docs/intro，得到 %v", got)
+    }
+}
+
+// 测试加权预算优先用于 HTML 页面，剩余预算再按比例用于资源
+func TestCrawlBudget(t *testing.T) {
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if strings.HasSuffix(r.URL.Path, ".png") {
+            w.Header().Set("Content-Type", "image/png")
+            fmt.Fprint(w, "PNG")
+            return
+        }
+        w.Header().Set("Content-Type", "text/html; charset=utf-8")
+        fmt.Fprint(w, "<html><body>")
+        if r.URL.Path == "/" {
+            // 资源链接排在页面链接前面
+            for i := 0; i < 3; i++ {
+                fmt.Fprintf(w, `<a href="/img%d.png">img</a>`, i)
+            }
+            for i := 0; i < 3; i++ {
+                fmt.Fprintf(w, `<a href="/page%d">page</a>`, i)
+            }
+        }
+        fmt.Fprint(w, "</body></html>")
+    }))
+    defer server.Close()
+
+    count := func(results []PageData) (pages, assets int) {
+        for _, page := range results {
+            if isAssetURL(page.URL) {
+                assets++
+            } else {
+                pages++
+            }
+        }
+        return pages, assets
+    }
+
+    config := CrawlerConfig{
+        StartURL:   server.URL + "/",
+        MaxDepth:   2,
+        MaxURLs:    50,
+        SameHost:   true,
+        Timeout:    5 * time.Second,
+        Concurrent: 1,
+        Budget:     4,
+        AssetCost:  0.5,
+    }
+    results, _ := crawl(context.Background(), config)
+    if pages, assets := count(results); pages != 4 || assets != 0 {
+        t.Errorf("预算为 4 时应只爬取 4 个 HTML 页面，得到 %d 个页面、%d 个资源", pages, assets)
+    }
+
+    config.Budget = 5
+    results, _ = crawl(context.Background(), config)
+    if pages, assets := count(results); pages != 4 || assets != 2 {
+        t.Errorf("预算为 5 时应爬取 4 个页面和 2 个资源，得到 %d 个页面、%d 个资源", pages, assets)
+    }
+    for _, page := range results {
+        if isAssetURL(page.URL) && page.ContentType != "image/png" {
+            t.Errorf("资源 %s 的内容类型应为 image/png，得到 %q", page.URL, page.ContentType)
+        }
+    }
+}
+
+// 测试只保存 200 响应的正文，清理后同名的 URL 保存为不同文件
+func TestFetchPageSaveDir(t *testing.T) {
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if r.URL.Path == "/missing" {
+            http.NotFound(w, r)
+            return
+        }
+        fmt.Fprintf(w, "<html><head><title>%s</title></head></html>", r.URL.RequestURI())
+    }))
+    defer server.Close()
+
+    dir := t.TempDir()
+    config := CrawlerConfig{Timeout: 5 * time.Second, SaveDir: dir}
+
+    first := fetchPage(context.Background(), server.URL+"/a?b", config, nil)
+    second := fetchPage(context.Background(), server.URL+"/a:b", config, nil)
+    if first.SavedPath == "" || second.SavedPath == "" || first.SavedPath == second.SavedPath {
+        t.Fatalf("两个页面应保存为不同文件，得到 %q 和 %q", first.SavedPath, second.SavedPath)
+    }
+    data, err := os.ReadFile(first.SavedPath)
+    if err != nil || !strings.Contains(string(data), "<title>/a?b</title>") {
+        t.Errorf("保存的正文不正确: %q, %v", data, err)
+    }
+    if first.Title != "/a?b" {
+        t.Errorf("保存正文后仍应解析标题，得到 %q", first.Title)
+    }
+
+    if page := fetchPage(context.Background(), server.URL+"/missing", config, nil); page.SavedPath != "" {
+        t.Errorf("非 200 响应不应保存，得到 %q", page.SavedPath)
+    }
+    if entries, _ := os.ReadDir(dir); len(entries) != 2 {
+        t.Errorf("目录中应有 2 个文件，得到 %d 个", len(entries))
+    }
+}
+
+// 测试请求携带 User-Agent 和附加的请求头
+func TestFetchPageHeaders(t *testing.T) {
+    var gotAgent, gotToken string
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        gotAgent = r.Header.Get("User-Agent")
+        gotToken = r.Header.Get("X-Token")
+        fmt.Fprint(w, "<html></html>")
+    }))
+    defer server.Close()
+
+    headers, err := parseHeaders([]string{"X-Token: abc"})
+    if err != nil {
+        t.Fatalf("解析请求头失败: %v", err)
+    }
+    config := CrawlerConfig{Timeout: 5 * time.Second, UserAgent: "test-agent/1.0", Headers: headers}
+    if page := fetchPage(context.Background(), server.URL, config, nil); page.Error != nil {
+        t.Fatalf("请求失败: %v", page.Error)
+    }
+    if gotAgent != "test-agent/1.0" || gotToken != "abc" {
+        t.Errorf("请求头不正确，User-Agent %q，X-Token %q", gotAgent, gotToken)
+    }
+
+    if _, err := parseHeaders([]string{"no-colon"}); err == nil {
+        t.Error("缺少冒号的请求头应报错")
+    }
+}
+
+// 测试记录实际读取的字节数并汇总出错页面
+func TestSummarizeBytes(t *testing.T) {
+    body := "<html><head><title>大小</title></head></html>"
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if r.URL.Path == "/down" {
+            w.WriteHeader(http.StatusInternalServerError)
+            return
+        }
+        fmt.Fprint(w, body)
+    }))
+    defer server.Close()
+
+    config := CrawlerConfig{Timeout: 5 * time.Second}
+    results := []PageData{
+        fetchPage(context.Background(), server.URL+"/a", config, nil),
+        fetchPage(context.Background(), server.URL+"/b", config, nil),
+        fetchPage(context.Background(), server.URL+"/down", config, nil),
+    }
+    if results[0].Bytes != int64(len(body)) {
+        t.Errorf("应记录 %d 字节，得到 %d", len(body), results[0].Bytes)
+    }
+
+    stats := summarize(results)
+    if stats.Bytes != int64(2*len(body)) || stats.Errors != 1 {
+        t.Errorf("汇总不正确: %+v", stats)
+    }
+    if avg := stats.averageBytes(); avg != float64(len(body)) {
+        t.Errorf("平均每页应为 %d 字节，得到 %.0f", len(body), avg)
+    }
+}
+
+// 测试每爬取 N 个页面写入一次结果快照
+func TestCrawlOutputEvery(t *testing.T) {
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        fmt.Fprint(w, "<html><body>")
+        if r.URL.Path == "/" {
+            for i := 0; i < 4; i++ {
+                fmt.Fprintf(w, `<a href="/page%d">page</a>`, i)
+            }
+        }
+        fmt.Fprint(w, "</body></html>")
+    }))
+    defer server.Close()
+
+    outputFile := filepath.Join(t.TempDir(), "snapshot.csv")
+    config := CrawlerConfig{
+        StartURL:    server.URL + "/",
+        MaxDepth:    1,
+        MaxURLs:     50,
+        SameHost:    true,
+        Timeout:     5 * time.Second,
+        Concurrent:  2,
+        OutputFile:  outputFile,
+        OutputEvery: 2,
+    }
+    results, _ := crawl(context.Background(), config)
+    if len(results) != 5 {
+        t.Fatalf("应爬取 5 个页面，得到 %d 个", len(results))
+    }
+
+    // 第 4 个页面之后写入的快照包含表头和 4 行，第 5 个页面不触发快照
+    data, err := os.ReadFile(outputFile)
+    if err != nil {
+        t.Fatalf("快照文件应存在: %v", err)
+    }
+    if lines := strings.Count(string(data), "\n"); lines != 5 {
+        t.Errorf("快照应包含表头和 4 个页面，得到 %d 行:\n%s", lines, data)
+    }
+}
+
+// 测试中断后不再爬取新页面，进行中的请求仍会完成并保留在结果中
+func TestCrawlInterrupted(t *testing.T) {
+    ctx, cancel := context.WithCancel(context.Background())
+    defer cancel()
+
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if r.URL.Path == "/page0" {
+            // 模拟请求进行中时收到中断信号
+            cancel()
+        }
+        fmt.Fprint(w, "<html><body>")
+        if r.URL.Path == "/" {
+            for i := 0; i < 5; i++ {
+                fmt.Fprintf(w, `<a href="/page%d">page</a>`, i)
+            }
+        }
+        fmt.Fprint(w, "</body></html>")
+    }))
+    defer server.Close()
+
+    config := CrawlerConfig{
+        StartURL:   server.URL + "/",
+        MaxDepth:   1,
+        MaxURLs:    50,
+        SameHost:   true,
+        Timeout:    5 * time.Second,
+        Concurrent: 1,
+    }
+    results, _ := crawl(ctx, config)
+
+    if len(results) != 2 {
+        t.Fatalf("中断后应只保留起始页面和进行中的页面，得到 %d 个", len(results))
+    }
+    if results[1].Error != nil {
+        t.Errorf("进行中的请求应正常完成，得到错误 %v", results[1].Error)
+    }
+}
+
+// 测试声明了 <base href> 的页面以该地址为基准解析相对链接和图片
+func TestBaseHref(t *testing.T) {
+    body := `<html><head><base href="/docs/v2/"><title>base</title></head><body>
+        <a href="guide.html">guide</a>
+        <a href="../about">about</a>
+        <a href="/root">root</a>
+        <img src="logo.png">
+    </body></html>`
+    page := parseHTML("http://a.com/blog/2024/post.html", strings.NewReader(body))
+    if page.BaseHref != "http://a.com/docs/v2/" {
+        t.Fatalf("BaseHref 应为 http://a.com/docs/v2/，得到 %q", page.BaseHref)
+    }
+    if len(page.Images) != 1 || page.Images[0] != "http://a.com/docs/v2/logo.png" {
+        t.Errorf("图片应以 base 解析，得到 %v", page.Images)
+    }
+
+    front := newFrontier(10)
+    enqueueLinks(context.Background(), front, page, page.Links, CrawlerConfig{MaxDepth: 1, MaxURLs: 10}, "a.com")
+    close(front.queue)
+
+    var got []string
+    for queued := range front.queue {
+        got = append(got, queued.URL)
+    }
+    expected := []string{"http://a.com/docs/v2/guide.html", "http://a.com/docs/about", "http://a.com/root"}
+    if strings.Join(got, ",") != strings.Join(expected, ",") {
+        t.Errorf("链接应以 base 解析为 %v，得到 %v", expected, got)
+    }
+
+    // 没有 <base> 时以页面 URL 解析
+    plain := parseHTML("http://a.com/blog/post.html", strings.NewReader(`<a href="next.html">next</a>`))
+    if base, _ := plain.linkBase(); plain.BaseHref != "" || base.String() != "http://a.com/blog/post.html" {
+        t.Errorf("没有 <base> 时应使用页面 URL，得到 %q", plain.BaseHref)
+    }
+}
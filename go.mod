@@ -4,7 +4,10 @@ go 1.23.0
 
 toolchain go1.24.1
 
-require github.com/fatih/color v1.18.0
+require (
+	github.com/fatih/color v1.18.0
+	golang.org/x/text v0.23.0
+)
 
 require (
 	github.com/chromedp/sysutil v1.1.0 // indirect
@@ -19,6 +22,6 @@ require (
 	github.com/chromedp/chromedp v0.13.3
 	github.com/mattn/go-colorable v0.1.13 // indirect
 	github.com/mattn/go-isatty v0.0.20 // indirect
-	golang.org/x/net v0.37.0 // indirect
+	golang.org/x/net v0.37.0
 	golang.org/x/sys v0.31.0 // indirect
 )